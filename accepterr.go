@@ -0,0 +1,24 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build !plan9
+// +build !plan9
+
+package service
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// isTemporaryAcceptError reports whether err, returned by accepting a
+// connection, is worth retrying: the process or system is out of file
+// descriptors, the connection was aborted before it was accepted, or the
+// accept timed out.
+func isTemporaryAcceptError(err error) bool {
+	if errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE) || errors.Is(err, syscall.ECONNABORTED) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -0,0 +1,16 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"errors"
+	"net"
+)
+
+// isTemporaryAcceptError reports whether err, returned by accepting a
+// connection, is worth retrying. Plan 9 has no error numbers, so only
+// timeouts are retried.
+func isTemporaryAcceptError(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -0,0 +1,17 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build !plan9
+// +build !plan9
+
+package service
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isAddrInUse reports whether err reports that an address is already in
+// use.
+func isAddrInUse(err error) bool {
+	return errors.Is(err, syscall.EADDRINUSE)
+}
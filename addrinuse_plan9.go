@@ -0,0 +1,12 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import "strings"
+
+// isAddrInUse reports whether err reports that an address is already in
+// use. Plan 9 reports network errors as strings rather than error
+// numbers.
+func isAddrInUse(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "address in use")
+}
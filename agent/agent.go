@@ -0,0 +1,142 @@
+// Copyright 2021 Canonical Ltd.
+
+// Package agent provides a runtime introspection agent for a service, in
+// the style of the gops agent, so that a running instance can be
+// debugged without being redeployed with profiling enabled.
+//
+// The agent listens on a unix socket and accepts one command per
+// connection, written as a single line, replying with the command's
+// output before closing the connection:
+//
+//	stack             the stacks of all goroutines
+//	memstats          the memory allocator statistics
+//	gc                run a garbage collection
+//	setgcpercent N    set the garbage collection target percentage
+//
+// so that, for example, the goroutines of a service can be dumped with:
+//
+//	echo stack | nc -U /run/myservice/agent.sock
+package agent
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/canonical/go-service"
+)
+
+// An Agent serves introspection commands for a service.
+type Agent struct {
+	// Path is the path of the unix socket the agent listens on. Any
+	// stale socket left at the path by a previous instance is removed.
+	Path string
+
+	// Authorize reports whether the peer with the given credentials may
+	// use the agent. If Authorize is nil only processes running as the
+	// same user as the service, or as root, are allowed. Connections
+	// whose peer credentials cannot be determined are always refused,
+	// so on platforms without peer credentials the agent refuses every
+	// connection.
+	Authorize func(*service.Credentials) bool
+
+	// Timeout limits the time each connection is given to send its
+	// command and read the reply. If Timeout is zero or negative 10s is
+	// used.
+	Timeout time.Duration
+}
+
+// Start starts the agent in a goroutine of the given service. The socket
+// is closed and removed when the service shuts down.
+func (a *Agent) Start(svc *service.Service) error {
+	if fi, err := os.Lstat(a.Path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(a.Path)
+	}
+	l, err := svc.Listen("unix", a.Path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(a.Path, 0600); err != nil {
+		l.Close()
+		return err
+	}
+	svc.ServeConns(l, a.handle, service.WithConnErrorHandler(func(net.Conn, error) {}))
+	return nil
+}
+
+// handle runs the command sent on a connection.
+func (a *Agent) handle(ctx context.Context, conn net.Conn) error {
+	cred, err := service.PeerCredentials(conn)
+	if err != nil {
+		return err
+	}
+	if !a.authorize(cred) {
+		return errors.New("agent: peer not authorized")
+	}
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return err
+	}
+	return run(conn, strings.Fields(line))
+}
+
+// authorize reports whether the given peer may use the agent.
+func (a *Agent) authorize(cred *service.Credentials) bool {
+	if a.Authorize != nil {
+		return a.Authorize(cred)
+	}
+	return cred.UID == os.Getuid() || cred.UID == 0
+}
+
+// run runs an agent command, writing its output to w.
+func run(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errors.New("agent: no command")
+	}
+	switch cmd := args[0]; {
+	case cmd == "stack" && len(args) == 1:
+		buf := make([]byte, 64<<10)
+		for {
+			n := runtime.Stack(buf, true)
+			if n < len(buf) {
+				_, err := w.Write(buf[:n])
+				return err
+			}
+			buf = make([]byte, 2*len(buf))
+		}
+	case cmd == "memstats" && len(args) == 1:
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		_, err := fmt.Fprintf(w, "alloc: %d\ntotal-alloc: %d\nsys: %d\nmallocs: %d\nfrees: %d\nheap-alloc: %d\nheap-sys: %d\nheap-idle: %d\nheap-inuse: %d\nheap-objects: %d\nnext-gc: %d\nnum-gc: %d\npause-total-ns: %d\ngoroutines: %d\n",
+			m.Alloc, m.TotalAlloc, m.Sys, m.Mallocs, m.Frees, m.HeapAlloc, m.HeapSys, m.HeapIdle, m.HeapInuse, m.HeapObjects, m.NextGC, m.NumGC, m.PauseTotalNs, runtime.NumGoroutine())
+		return err
+	case cmd == "gc" && len(args) == 1:
+		runtime.GC()
+		_, err := io.WriteString(w, "ok\n")
+		return err
+	case cmd == "setgcpercent" && len(args) == 2:
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintf(w, "invalid percentage %q\n", args[1])
+			return err
+		}
+		_, err = fmt.Fprintf(w, "previous: %d\n", debug.SetGCPercent(n))
+		return err
+	}
+	fmt.Fprintf(w, "unknown command %q\n", strings.Join(args, " "))
+	return fmt.Errorf("agent: unknown command %q", args[0])
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 Canonical Ltd.
+
+package agent
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-service"
+)
+
+func TestAgent(t *testing.T) {
+	_, svc := service.NewService(context.Background())
+	a := &Agent{Path: filepath.Join(t.TempDir(), "agent.sock")}
+	if err := a.Start(svc); err != nil {
+		t.Fatal(err)
+	}
+	deny := &Agent{
+		Path:      filepath.Join(t.TempDir(), "deny.sock"),
+		Authorize: func(*service.Credentials) bool { return false },
+	}
+	if err := deny.Start(svc); err != nil {
+		t.Fatal(err)
+	}
+	command := func(path, cmd string) string {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		io.WriteString(conn, cmd+"\n")
+		out, _ := io.ReadAll(conn)
+		return string(out)
+	}
+
+	if out := command(a.Path, "stack"); !strings.Contains(out, "goroutine ") {
+		t.Errorf("unexpected stack output %q", out)
+	}
+	if out := command(a.Path, "memstats"); !strings.Contains(out, "heap-alloc: ") {
+		t.Errorf("unexpected memstats output %q", out)
+	}
+	if out := command(a.Path, "gc"); out != "ok\n" {
+		t.Errorf("unexpected gc output %q", out)
+	}
+	defer debug.SetGCPercent(debug.SetGCPercent(100))
+	command(a.Path, "setgcpercent 50")
+	if out := command(a.Path, "setgcpercent 100"); out != "previous: 50\n" {
+		t.Errorf("unexpected setgcpercent output %q", out)
+	}
+	if out := command(a.Path, "rm -rf"); out != "unknown command \"rm -rf\"\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	if out := command(deny.Path, "gc"); out != "" {
+		t.Errorf("unauthorized command ran: %q", out)
+	}
+
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if _, err := net.Dial("unix", a.Path); err == nil {
+		t.Error("agent still listening after shutdown")
+	}
+}
+
+func TestAgentNegativeTimeout(t *testing.T) {
+	_, svc := service.NewService(context.Background())
+	a := &Agent{Path: filepath.Join(t.TempDir(), "agent.sock"), Timeout: -time.Second}
+	if err := a.Start(svc); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("unix", a.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// With a deadline in the past the command would never be read.
+	time.Sleep(10 * time.Millisecond)
+	io.WriteString(conn, "gc\n")
+	if out, _ := io.ReadAll(conn); string(out) != "ok\n" {
+		t.Errorf("unexpected gc output %q", out)
+	}
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Beacon states reported in a Heartbeat.
+const (
+	BeaconRunning  = "running"
+	BeaconStopping = "stopping"
+)
+
+// A Beacon periodically reports the liveness of a service to an external
+// collector. A Beacon is normally run as one of the goroutines of a
+// service:
+//
+//	svc.Go(func() error { return b.Run(ctx) })
+type Beacon struct {
+	// URL is the endpoint heartbeats are sent to. For http and https URLs
+	// each heartbeat is sent as the JSON body of a POST request. For udp
+	// URLs each heartbeat is sent as a single JSON datagram to the host
+	// and port in the URL.
+	URL string
+
+	// Interval is the time between heartbeats. If this is zero or negative
+	// a heartbeat is sent every 30 seconds.
+	Interval time.Duration
+
+	// Timeout is the maximum time allowed to send a heartbeat. If this is
+	// zero or negative the Interval is used.
+	Timeout time.Duration
+
+	// Instance identifies the instance of the service in each heartbeat.
+	Instance string
+
+	// Version is the version of the service reported in each heartbeat.
+	Version string
+
+	// Metadata is additional metadata reported in each heartbeat.
+	Metadata map[string]string
+
+	// Client is the client used to send HTTP heartbeats. If this is nil
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	// OnError, if set, is called for each heartbeat that cannot be sent.
+	// Failing to send a heartbeat does not stop the beacon.
+	OnError func(error)
+
+	budget *ShutdownBudget
+}
+
+// stoppingTimeout is the maximum time allowed to send the final
+// heartbeat, so that an unresponsive endpoint does not hold up shutdown.
+const stoppingTimeout = 5 * time.Second
+
+// A Heartbeat is the message sent by a Beacon.
+type Heartbeat struct {
+	Instance string            `json:"instance,omitempty"`
+	Version  string            `json:"version,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	State    string            `json:"state"`
+	Time     time.Time         `json:"time"`
+}
+
+// NewBeacon returns a Beacon sending heartbeats to the given URL which
+// identifies the service with its instance ID and metadata. The final
+// heartbeat sent by the beacon is bounded by the service's shutdown
+// budget.
+func (s *Service) NewBeacon(url string) *Beacon {
+	return &Beacon{
+		URL:      url,
+		Instance: s.InstanceID(),
+		Metadata: s.Metadata(),
+		budget:   s.budget,
+	}
+}
+
+// Run sends heartbeats until the given context is done, at which point a
+// final heartbeat with the BeaconStopping state is sent before Run
+// returns. The final heartbeat is allowed no more than the Timeout, up to
+// five seconds, and, for a beacon created with NewBeacon, no more than
+// the rest of the shutdown budget. Run only returns an error if the
+// beacon is misconfigured.
+func (b *Beacon) Run(ctx context.Context) error {
+	send, err := b.sender()
+	if err != nil {
+		return err
+	}
+	interval := b.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	timeout := b.Timeout
+	if timeout <= 0 {
+		timeout = interval
+	}
+	beat := func(ctx context.Context, state string) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		buf, err := json.Marshal(Heartbeat{
+			Instance: b.Instance,
+			Version:  b.Version,
+			Metadata: b.Metadata,
+			State:    state,
+			Time:     time.Now().UTC(),
+		})
+		if err == nil {
+			err = send(ctx, buf)
+		}
+		if err != nil && b.OnError != nil {
+			b.OnError(err)
+		}
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	beat(ctx, BeaconRunning)
+	for {
+		select {
+		case <-t.C:
+			beat(ctx, BeaconRunning)
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), stoppingTimeout)
+			defer cancel()
+			if b.budget != nil {
+				ctx, cancel = b.budget.earlyContext(ctx)
+				defer cancel()
+			}
+			beat(ctx, BeaconStopping)
+			return nil
+		}
+	}
+}
+
+func (b *Beacon) sender() (func(context.Context, []byte) error, error) {
+	u, err := url.Parse(b.URL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		client := b.Client
+		if client == nil {
+			client = http.DefaultClient
+		}
+		return func(ctx context.Context, buf []byte) error {
+			req, err := http.NewRequestWithContext(ctx, "POST", b.URL, bytes.NewReader(buf))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				return fmt.Errorf("beacon: unexpected response from %s: %s", b.URL, resp.Status)
+			}
+			return nil
+		}, nil
+	case "udp":
+		return func(ctx context.Context, buf []byte) error {
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "udp", u.Host)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			if deadline, ok := ctx.Deadline(); ok {
+				conn.SetWriteDeadline(deadline)
+			}
+			_, err = conn.Write(buf)
+			return err
+		}, nil
+	default:
+		return nil, fmt.Errorf("beacon: unsupported URL scheme %q", u.Scheme)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBeaconHTTP(t *testing.T) {
+	beats := make(chan Heartbeat, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var hb Heartbeat
+		if err := json.NewDecoder(req.Body).Decode(&hb); err != nil {
+			t.Error("cannot decode heartbeat:", err)
+		}
+		beats <- hb
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Beacon{
+		URL:      srv.URL,
+		Interval: time.Hour,
+		Instance: "test-1",
+		Version:  "1.0",
+	}
+	errC := make(chan error)
+	go func() { errC <- b.Run(ctx) }()
+	if hb := <-beats; hb.State != BeaconRunning || hb.Instance != "test-1" || hb.Version != "1.0" {
+		t.Errorf("unexpected heartbeat: %+v", hb)
+	}
+	cancel()
+	if err := <-errC; err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if hb := <-beats; hb.State != BeaconStopping {
+		t.Errorf("unexpected heartbeat: %+v", hb)
+	}
+}
+
+func TestBeaconShutdownBudget(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var hb Heartbeat
+		json.NewDecoder(req.Body).Decode(&hb)
+		if hb.State == BeaconStopping {
+			select {
+			case <-release:
+			case <-req.Context().Done():
+			}
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, svc := New(context.Background(), WithShutdownTimeout(50*time.Millisecond))
+	b := svc.NewBeacon(srv.URL)
+	b.Interval = time.Hour
+	svc.Go(func() error { return b.Run(ctx) })
+	svc.Shutdown(nil)
+	start := time.Now()
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Error("final heartbeat held up shutdown for", d)
+	}
+}
+
+func TestBeaconUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := &Beacon{URL: "udp://" + pc.LocalAddr().String(), Interval: time.Hour}
+	errC := make(chan error)
+	go func() { errC <- b.Run(ctx) }()
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	for _, state := range []string{BeaconRunning, BeaconStopping} {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var hb Heartbeat
+		if err := json.Unmarshal(buf[:n], &hb); err != nil {
+			t.Fatal(err)
+		}
+		if hb.State != state {
+			t.Errorf("unexpected heartbeat: %+v", hb)
+		}
+		cancel()
+	}
+	if err := <-errC; err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestBeaconNegativeInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b := &Beacon{URL: "udp://127.0.0.1:9", Interval: -time.Second, Timeout: -time.Second}
+	if err := b.Run(ctx); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestBeaconUnsupportedScheme(t *testing.T) {
+	b := &Beacon{URL: "ftp://example.com"}
+	err := b.Run(context.Background())
+	if err == nil || err.Error() != `beacon: unsupported URL scheme "ftp"` {
+		t.Error("unexpected error:", err)
+	}
+}
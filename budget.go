@@ -0,0 +1,204 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// A ShutdownBudget is the time allowed for a service to shut down,
+// shared by everything that runs during shutdown. The budget starts when
+// the service starts shutting down. Shutdown functions can query the
+// budget to bound their own work, rather than each using an independent
+// timeout that together overshoot the time allowed.
+type ShutdownBudget struct {
+	total time.Duration
+
+	once     sync.Once
+	mu       sync.Mutex
+	start    time.Time
+	hooks    time.Duration
+	runs     int
+	reserved time.Duration
+}
+
+// A BudgetUsage reports how a shutdown budget has been used.
+type BudgetUsage struct {
+	// Elapsed is the time since the budget started.
+	Elapsed time.Duration
+
+	// Hooks is the total time spent running shutdown functions.
+	Hooks time.Duration
+
+	// HooksRun is the number of shutdown functions that have completed.
+	HooksRun int
+}
+
+// ShutdownBudget returns the service's shutdown budget, which is set
+// using WithShutdownTimeout.
+func (s *Service) ShutdownBudget() *ShutdownBudget {
+	return s.budget
+}
+
+// Total returns the time allowed for shutdown. If this is zero the time
+// allowed is unlimited.
+func (b *ShutdownBudget) Total() time.Duration {
+	return b.total
+}
+
+// Deadline returns the time by which shutdown must complete. It returns
+// false if the budget is unlimited or has not started.
+func (b *ShutdownBudget) Deadline() (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.total <= 0 || b.start.IsZero() {
+		return time.Time{}, false
+	}
+	return b.start.Add(b.total), true
+}
+
+// Remaining returns the time left in the budget, which is never negative.
+// If the budget is unlimited Remaining returns the maximum duration, and
+// if it has not started the total budget is returned.
+func (b *ShutdownBudget) Remaining() time.Duration {
+	if b.total <= 0 {
+		return math.MaxInt64
+	}
+	deadline, ok := b.Deadline()
+	if !ok {
+		return b.total
+	}
+	if d := time.Until(deadline); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Context returns a context derived from the given one that expires when
+// the budget runs out.
+func (b *ShutdownBudget) Context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := b.Deadline(); ok {
+		return context.WithDeadline(ctx, deadline)
+	}
+	if b.total > 0 {
+		return context.WithTimeout(ctx, b.total)
+	}
+	return context.WithCancel(ctx)
+}
+
+// earlyContext returns a context derived from the given one that expires
+// hookSlack before the budget runs out, so that work bounded by it has
+// time to return before the service's shutdown times out.
+func (b *ShutdownBudget) earlyContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.total <= 0 {
+		return context.WithCancel(ctx)
+	}
+	deadline, ok := b.Deadline()
+	if !ok {
+		deadline = time.Now().Add(b.total)
+	}
+	return context.WithDeadline(ctx, deadline.Add(-hookSlack))
+}
+
+// Reserved returns the part of the budget reserved for the shutdown
+// functions registered with OnShutdownCritical.
+func (b *ShutdownBudget) Reserved() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.reserved
+}
+
+// reserve reserves part of the budget for a critical shutdown function.
+// A negative d reserves nothing.
+func (b *ShutdownBudget) reserve(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reserved += d
+}
+
+// hookSlack is the time allowed between the deadline given to shutdown
+// functions that are not critical and them being abandoned, so that a
+// function that stops its work at the deadline has time to return.
+const hookSlack = 10 * time.Millisecond
+
+// criticalStart returns the time at which only the reserved part of the
+// budget is left, when shutdown functions that are not critical are
+// abandoned. It returns false if the budget is unlimited or has not
+// started.
+func (b *ShutdownBudget) criticalStart() (time.Time, bool) {
+	deadline, ok := b.Deadline()
+	if !ok {
+		return time.Time{}, false
+	}
+	return deadline.Add(-b.Reserved()), true
+}
+
+// hookDeadline returns the time by which the shutdown functions that are
+// not critical should complete, leaving the reserved part of the budget
+// for the critical ones. It returns false if the budget is unlimited or
+// has not started.
+func (b *ShutdownBudget) hookDeadline() (time.Time, bool) {
+	t, ok := b.criticalStart()
+	if ok && b.Reserved() > 0 {
+		t = t.Add(-hookSlack)
+	}
+	return t, ok
+}
+
+// hookContext returns a context derived from the given one that expires
+// when the part of the budget for shutdown functions that are not
+// critical runs out.
+func (b *ShutdownBudget) hookContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := b.hookDeadline(); ok {
+		return context.WithDeadline(ctx, deadline)
+	}
+	if b.total > 0 {
+		d := b.total - b.Reserved()
+		if b.Reserved() > 0 {
+			d -= hookSlack
+		}
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Usage returns the current usage of the budget.
+func (b *ShutdownBudget) Usage() BudgetUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	u := BudgetUsage{
+		Hooks:    b.hooks,
+		HooksRun: b.runs,
+	}
+	if !b.start.IsZero() {
+		u.Elapsed = time.Since(b.start)
+	}
+	return u
+}
+
+// begin starts the budget, if it has not already started.
+func (b *ShutdownBudget) begin() {
+	b.once.Do(func() {
+		b.mu.Lock()
+		b.start = time.Now()
+		b.mu.Unlock()
+	})
+}
+
+// runHook runs a shutdown function, charging the time it takes to the
+// budget.
+func (b *ShutdownBudget) runHook(f func()) {
+	start := time.Now()
+	f()
+	d := time.Since(start)
+	b.mu.Lock()
+	b.hooks += d
+	b.runs++
+	b.mu.Unlock()
+}
@@ -0,0 +1,55 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShutdownBudget(t *testing.T) {
+	_, svc := New(context.Background(), WithShutdownTimeout(time.Hour))
+	b := svc.ShutdownBudget()
+	if b.Remaining() != time.Hour {
+		t.Error("unexpected remaining budget before shutdown:", b.Remaining())
+	}
+	var hookDeadline time.Time
+	svc.OnShutdown(func() {
+		ctx, cancel := b.Context(context.Background())
+		defer cancel()
+		hookDeadline, _ = ctx.Deadline()
+	})
+	svc.OnShutdown(func() {
+		time.Sleep(10 * time.Millisecond)
+	})
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+	deadline, ok := b.Deadline()
+	if !ok || !hookDeadline.Equal(deadline) {
+		t.Errorf("hook deadline %v does not match budget deadline %v", hookDeadline, deadline)
+	}
+	if r := b.Remaining(); r > time.Hour-10*time.Millisecond {
+		t.Error("budget not drawn down:", r)
+	}
+	u := b.Usage()
+	if u.HooksRun != 2 || u.Hooks < 10*time.Millisecond || u.Elapsed < u.Hooks {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+}
+
+func TestShutdownBudgetUnlimited(t *testing.T) {
+	_, svc := NewService(context.Background())
+	b := svc.ShutdownBudget()
+	if b.Total() != 0 {
+		t.Error("unexpected total:", b.Total())
+	}
+	if _, ok := b.Deadline(); ok {
+		t.Error("unlimited budget has a deadline")
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// A Bus delivers events published by the goroutines of a service to the
+// goroutines subscribed to them. Events are delivered on typed topics, so
+// that publishers and subscribers agree on the type of the events
+// without type assertions.
+//
+// Each subscriber has a bounded queue. Publishing never blocks: an event
+// that does not fit in a subscriber's queue is dropped for that
+// subscriber and counted by its Dropped method, so that a slow subscriber
+// cannot hold up the rest of the service. When the service starts
+// shutting down every subscription is closed, so that subscribers ranging
+// over their channels return.
+type Bus struct {
+	mu     sync.Mutex
+	subs   map[string]map[*subscriber]struct{}
+	closed bool
+}
+
+// A subscriber is a subscription to a topic of a bus, of any event type.
+type subscriber struct {
+	send  func(v interface{}) bool
+	close func()
+}
+
+// A Topic is a named topic of a bus, carrying events of type T. Topics
+// with the same name must have the same type.
+type Topic[T any] struct {
+	name string
+}
+
+// NewTopic returns the topic with the given name.
+func NewTopic[T any](name string) Topic[T] {
+	return Topic[T]{name: name}
+}
+
+// Name returns the name of the topic.
+func (t Topic[T]) Name() string {
+	return t.name
+}
+
+// A Subscription receives the events published to a topic.
+type Subscription[T any] struct {
+	// C receives the events. It is closed once the subscription is
+	// canceled or the service starts shutting down.
+	C <-chan T
+
+	bus     *Bus
+	topic   string
+	sub     *subscriber
+	dropped atomic.Uint64
+}
+
+// Bus returns the event bus of the service.
+func (s *Service) Bus() *Bus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bus == nil {
+		s.bus = newBus()
+		context.AfterFunc(s.groupCtx, s.bus.close)
+	}
+	return s.bus
+}
+
+// newBus returns a new bus.
+func newBus() *Bus {
+	return &Bus{subs: make(map[string]map[*subscriber]struct{})}
+}
+
+// Subscribe subscribes to the given topic of the bus, with a queue of the
+// given size. Subscribing after the service has started shutting down
+// returns a subscription whose channel is already closed.
+func Subscribe[T any](b *Bus, t Topic[T], size int) *Subscription[T] {
+	c := make(chan T, size)
+	sub := &Subscription[T]{C: c, bus: b, topic: t.name}
+	var once sync.Once
+	sub.sub = &subscriber{
+		send: func(v interface{}) bool {
+			select {
+			case c <- v.(T):
+				return true
+			default:
+				sub.dropped.Add(1)
+				return false
+			}
+		},
+		close: func() { once.Do(func() { close(c) }) },
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		sub.sub.close()
+		return sub
+	}
+	if b.subs[t.name] == nil {
+		b.subs[t.name] = make(map[*subscriber]struct{})
+	}
+	b.subs[t.name][sub.sub] = struct{}{}
+	return sub
+}
+
+// Publish publishes an event to every subscriber of the given topic of
+// the bus, returning the number of subscribers it was delivered to.
+func Publish[T any](b *Bus, t Topic[T], v T) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for sub := range b.subs[t.name] {
+		if sub.send(v) {
+			n++
+		}
+	}
+	return n
+}
+
+// Unsubscribe cancels the subscription and closes its channel. Events
+// already queued can still be received.
+func (sub *Subscription[T]) Unsubscribe() {
+	b := sub.bus
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if subs := b.subs[sub.topic]; subs != nil {
+		delete(subs, sub.sub)
+		if len(subs) == 0 {
+			delete(b.subs, sub.topic)
+		}
+	}
+	sub.sub.close()
+}
+
+// Dropped returns the number of events that were dropped because the
+// subscription's queue was full.
+func (sub *Subscription[T]) Dropped() uint64 {
+	return sub.dropped.Load()
+}
+
+// close closes every subscription to the bus.
+func (b *Bus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for _, subs := range b.subs {
+		for sub := range subs {
+			sub.close()
+		}
+	}
+	b.subs = nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	_, svc := NewService(context.Background())
+	topic := NewTopic[string]("jobs")
+	a := Subscribe(svc.Bus(), topic, 1)
+	b := Subscribe(svc.Bus(), topic, 2)
+
+	if n := Publish(svc.Bus(), topic, "one"); n != 2 {
+		t.Error("unexpected delivery count:", n)
+	}
+	if n := Publish(svc.Bus(), topic, "two"); n != 1 {
+		t.Error("unexpected delivery count:", n)
+	}
+	if a.Dropped() != 1 || b.Dropped() != 0 {
+		t.Error("unexpected drop counts:", a.Dropped(), b.Dropped())
+	}
+	if v := <-a.C; v != "one" {
+		t.Error("unexpected event:", v)
+	}
+	a.Unsubscribe()
+	if _, ok := <-a.C; ok {
+		t.Error("subscription not closed")
+	}
+	if n := Publish(svc.Bus(), topic, "three"); n != 0 {
+		t.Error("unexpected delivery count:", n)
+	}
+
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	var got []string
+	for v := range b.C {
+		got = append(got, v)
+	}
+	if len(got) != 2 || got[0] != "one" || got[1] != "two" {
+		t.Error("unexpected events:", got)
+	}
+	if _, ok := <-Subscribe(svc.Bus(), topic, 1).C; ok {
+		t.Error("subscription after shutdown not closed")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build chaos
+// +build chaos
+
+package service
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrChaos is the error returned by goroutines failed by WithChaos.
+var ErrChaos = errors.New("chaos: injected worker error")
+
+// A ChaosConfig configures the faults injected by WithChaos.
+type ChaosConfig struct {
+	// Seed seeds the random source, so that a failing run can be
+	// reproduced.
+	Seed int64
+
+	// MaxHookDelay is the maximum random delay added before each
+	// function registered with OnShutdown runs.
+	MaxHookDelay time.Duration
+
+	// WorkerErrorRate is the probability, between 0 and 1, that each
+	// goroutine started with Go fails with ErrChaos instead of running.
+	WorkerErrorRate float64
+
+	// MaxWorkerDelay is the maximum random time after which an injected
+	// worker error is returned.
+	MaxWorkerDelay time.Duration
+
+	// Signal, if set, is sent to the process at random times up to
+	// MaxSignalDelay after the service is created.
+	Signal         os.Signal
+	MaxSignalDelay time.Duration
+}
+
+// WithChaos injects random faults into the service to exercise the
+// shutdown paths of the program in integration tests. It is only
+// available when built with the chaos build tag, so it cannot be
+// accidentally enabled in production builds.
+func WithChaos(cfg ChaosConfig) Option {
+	c := &chaos{
+		cfg: cfg,
+		rnd: rand.New(rand.NewSource(cfg.Seed)),
+	}
+	return func(o *options) {
+		o.wrapGo = c.wrapGo
+		o.wrapHook = c.wrapHook
+		o.start = c.start
+	}
+}
+
+type chaos struct {
+	cfg ChaosConfig
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func (c *chaos) wrapGo(f func() error) func() error {
+	if c.float64() >= c.cfg.WorkerErrorRate {
+		return f
+	}
+	d := c.duration(c.cfg.MaxWorkerDelay)
+	return func() error {
+		time.Sleep(d)
+		return ErrChaos
+	}
+}
+
+func (c *chaos) wrapHook(f func()) func() {
+	d := c.duration(c.cfg.MaxHookDelay)
+	return func() {
+		time.Sleep(d)
+		f()
+	}
+}
+
+func (c *chaos) start(s *Service) {
+	if c.cfg.Signal == nil {
+		return
+	}
+	d := c.duration(c.cfg.MaxSignalDelay)
+	go func() {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-s.doneC:
+		case <-t.C:
+			if p, err := os.FindProcess(os.Getpid()); err == nil {
+				p.Signal(c.cfg.Signal)
+			}
+		}
+	}()
+}
+
+func (c *chaos) float64() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rnd.Float64()
+}
+
+func (c *chaos) duration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Duration(c.rnd.Int63n(int64(max)))
+}
@@ -0,0 +1,47 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build chaos
+// +build chaos
+
+package service
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestChaosWorkerError(t *testing.T) {
+	ctx, svc := New(context.Background(), WithChaos(ChaosConfig{
+		WorkerErrorRate: 1,
+		MaxHookDelay:    time.Millisecond,
+	}))
+	hookRan := false
+	svc.OnShutdown(func() { hookRan = true })
+	svc.Go(func() error {
+		<-ctx.Done()
+		return nil
+	})
+	if err := svc.Wait(); err != ErrChaos {
+		t.Error("unexpected error:", err)
+	}
+	if !hookRan {
+		t.Error("shutdown hook not run")
+	}
+}
+
+func TestChaosSignal(t *testing.T) {
+	_, svc := New(context.Background(),
+		WithSignals(syscall.SIGUSR1),
+		WithChaos(ChaosConfig{
+			Seed:           1,
+			Signal:         syscall.SIGUSR1,
+			MaxSignalDelay: 10 * time.Millisecond,
+		}),
+	)
+	err := svc.Wait()
+	if err.Error() != "received user defined signal 1" {
+		t.Error("unexpected error:", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// A CheckpointStore stores the checkpointed state of named components.
+type CheckpointStore interface {
+	// Load returns the most recently saved checkpoint with the given
+	// name. If there is no such checkpoint the error returned satisfies
+	// errors.Is(err, fs.ErrNotExist).
+	Load(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// Save stores a checkpoint with the given name, replacing any
+	// previous checkpoint with that name.
+	Save(ctx context.Context, name string, r io.Reader) error
+}
+
+// A CheckpointError is the error recorded when a checkpoint cannot be
+// loaded or saved.
+type CheckpointError struct {
+	Name string
+	Err  error
+}
+
+// Error implements the error interface.
+func (e *CheckpointError) Error() string {
+	return "checkpoint " + e.Name + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *CheckpointError) Unwrap() error {
+	return e.Err
+}
+
+// Checkpointable registers a stateful component whose state is saved to
+// the service's checkpoint store, set using WithCheckpointStore, when the
+// service shuts down and restored when it next starts.
+//
+// Checkpointable calls load immediately with the last checkpoint saved
+// under the given name, if there is one, and returns any error loading
+// it. The save function is registered with OnShutdown, so it is called
+// when the service starts shutting down, and errors saving the checkpoint
+// are reported by ShutdownErrors.
+func (s *Service) Checkpointable(name string, save func(context.Context, io.Writer) error, load func(context.Context, io.Reader) error) error {
+	store := s.opts.checkpoints
+	if store == nil {
+		return &CheckpointError{Name: name, Err: errors.New("no checkpoint store")}
+	}
+	ctx := context.WithoutCancel(s.ctx)
+	r, err := store.Load(ctx, name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return &CheckpointError{Name: name, Err: err}
+	default:
+		err = load(ctx, r)
+		r.Close()
+		if err != nil {
+			return &CheckpointError{Name: name, Err: err}
+		}
+	}
+	s.OnShutdown(func() {
+		var buf bytes.Buffer
+		err := save(ctx, &buf)
+		if err == nil {
+			err = store.Save(ctx, name, &buf)
+		}
+		if err != nil {
+			s.addShutdownError(&CheckpointError{Name: name, Err: err})
+		}
+	})
+	return nil
+}
+
+// DirCheckpointStore is a CheckpointStore that stores each checkpoint as a
+// file in a directory.
+type DirCheckpointStore string
+
+// Load implements CheckpointStore.
+func (d DirCheckpointStore) Load(_ context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), name))
+}
+
+// Save implements CheckpointStore. The checkpoint is written to a
+// temporary file which then replaces the previous checkpoint, so a
+// failed save never leaves a partial checkpoint behind.
+func (d DirCheckpointStore) Save(_ context.Context, name string, r io.Reader) error {
+	f, err := os.CreateTemp(string(d), "."+name+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(string(d), name))
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCheckpointable(t *testing.T) {
+	store := DirCheckpointStore(t.TempDir())
+	state := "initial"
+	save := func(_ context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, state)
+		return err
+	}
+	load := func(_ context.Context, r io.Reader) error {
+		buf, err := io.ReadAll(r)
+		state = string(buf)
+		return err
+	}
+
+	_, svc := New(context.Background(), WithCheckpointStore(store))
+	if err := svc.Checkpointable("counter", save, load); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if state != "initial" {
+		t.Errorf("unexpected state %q", state)
+	}
+	state = "saved"
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	svc.Wait()
+	if errs := svc.ShutdownErrors(); len(errs) != 0 {
+		t.Error("unexpected shutdown errors:", errs)
+	}
+
+	state = ""
+	_, svc = New(context.Background(), WithCheckpointStore(store))
+	if err := svc.Checkpointable("counter", save, load); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if state != "saved" {
+		t.Errorf("unexpected state %q", state)
+	}
+}
+
+func TestCheckpointSaveError(t *testing.T) {
+	_, svc := New(context.Background(), WithCheckpointStore(DirCheckpointStore(t.TempDir())))
+	err := svc.Checkpointable("broken", func(context.Context, io.Writer) error {
+		return errors.New("cannot save")
+	}, func(context.Context, io.Reader) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+	errs := svc.ShutdownErrors()
+	if len(errs) != 1 || errs[0].Error() != "checkpoint broken: cannot save" {
+		t.Error("unexpected shutdown errors:", errs)
+	}
+}
+
+func TestCheckpointableNoStore(t *testing.T) {
+	_, svc := New(context.Background())
+	err := svc.Checkpointable("x", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "no checkpoint store") {
+		t.Error("unexpected error:", err)
+	}
+}
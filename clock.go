@@ -0,0 +1,29 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import "time"
+
+// A Clock tells the time and waits for it to pass. Code that takes its
+// clock from a ServiceRunner, rather than using the time package
+// directly, can be tested with the fake clock in the servicetest
+// package.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After returns a channel that receives the current time once d has
+	// elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the Clock implemented by the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Clock returns the clock of the service, which is the system clock.
+func (s *Service) Clock() Clock {
+	return systemClock{}
+}
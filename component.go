@@ -0,0 +1,246 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// A Component is a part of a service that needs to be started before the
+// service is ready and stopped when it shuts down, such as a database
+// connection, cache or server.
+type Component interface {
+	// Start starts the component. The context expires if the service
+	// shuts down or the start timeout runs out.
+	Start(ctx context.Context) error
+
+	// Stop stops the component. The context expires when the shutdown
+	// budget or the stop timeout runs out.
+	Stop(ctx context.Context) error
+}
+
+// A ComponentOption configures a component added with Add.
+type ComponentOption func(*componentOptions)
+
+type componentOptions struct {
+	startTimeout time.Duration
+	stopTimeout  time.Duration
+	deps         []Component
+}
+
+// WithStartTimeout limits the time the component's Start method is
+// given to return.
+func WithStartTimeout(d time.Duration) ComponentOption {
+	return func(o *componentOptions) {
+		o.startTimeout = d
+	}
+}
+
+// WithStopTimeout limits the time the component's Stop method is given
+// to return.
+func WithStopTimeout(d time.Duration) ComponentOption {
+	return func(o *componentOptions) {
+		o.stopTimeout = d
+	}
+}
+
+// DependsOn declares that the component depends on the given components,
+// so that it is only started once they have started and is stopped
+// before they are. The components depended on need not have been added
+// yet, but must be added before the service starts.
+func DependsOn(deps ...Component) ComponentOption {
+	return func(o *componentOptions) {
+		o.deps = append(o.deps, deps...)
+	}
+}
+
+// A component is a component added to a service.
+type component struct {
+	c    Component
+	opts componentOptions
+
+	// started is closed once the component's Start method has returned,
+	// and ok is whether it returned successfully.
+	started chan struct{}
+	ok      bool
+
+	// stopped is closed once the component has been stopped, or would
+	// have been if it had started.
+	stopped chan struct{}
+}
+
+// Add adds a component to the service. Components are started by the
+// start functions of the service, as described by OnStart, each as soon
+// as the components it depends on have started, so that independent
+// components start concurrently. When the service shuts down each
+// component that started successfully is stopped once the components
+// depending on it have been stopped.
+//
+// If a component fails to start the service shuts down with its error,
+// and the components depending on it are not started. Errors stopping a
+// component are reported by ShutdownErrors. Components are identified by
+// comparing them with ==, so they are normally pointers. Adding a
+// component twice, or adding a component whose dependencies would form a
+// cycle, panics with a *MisuseError.
+func (s *Service) Add(c Component, opts ...ComponentOption) {
+	if s.opts.strict && c == nil {
+		misuse("Add", "called with a nil component")
+	}
+	comp := &component{c: c, started: make(chan struct{}), stopped: make(chan struct{})}
+	for _, opt := range opts {
+		opt(&comp.opts)
+	}
+
+	s.mu.Lock()
+	if _, ok := s.components[c]; ok {
+		s.mu.Unlock()
+		misuse("Add", fmt.Sprintf("called twice with component %T", c))
+	}
+	if s.dependsOn(comp.opts.deps, c, make(map[Component]bool)) {
+		s.mu.Unlock()
+		misuse("Add", fmt.Sprintf("called with component %T whose dependencies form a cycle", c))
+	}
+	if s.components == nil {
+		s.components = make(map[Component]*component)
+	}
+	s.components[c] = comp
+	s.pendingComponents = append(s.pendingComponents, comp)
+	first := len(s.pendingComponents) == 1
+	s.mu.Unlock()
+
+	if first {
+		s.OnStart(s.startComponents)
+	}
+}
+
+// dependsOn reports whether any of the given dependencies is, or depends
+// on, the given component. The caller must hold s.mu.
+func (s *Service) dependsOn(deps []Component, c Component, seen map[Component]bool) bool {
+	for _, d := range deps {
+		if d == c {
+			return true
+		}
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		if dc, ok := s.components[d]; ok && s.dependsOn(dc.opts.deps, c, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// startComponents starts the components added since it was last called,
+// and registers a shutdown function to stop them.
+func (s *Service) startComponents(ctx context.Context) error {
+	s.mu.Lock()
+	batch := s.pendingComponents
+	s.pendingComponents = nil
+	deps := make(map[*component][]*component, len(batch))
+	for _, comp := range batch {
+		for _, d := range comp.opts.deps {
+			dc, ok := s.components[d]
+			if !ok {
+				s.mu.Unlock()
+				return fmt.Errorf("component %T depends on a component of type %T that was not added", comp.c, d)
+			}
+			deps[comp] = append(deps[comp], dc)
+		}
+	}
+	s.mu.Unlock()
+
+	s.OnShutdownContext(func(ctx context.Context) error {
+		return stopComponents(ctx, batch, deps)
+	})
+
+	g, ctx := errgroup.WithContext(ctx)
+	for _, comp := range batch {
+		comp := comp
+		g.Go(func() error {
+			defer close(comp.started)
+			for _, d := range deps[comp] {
+				select {
+				case <-d.started:
+				case <-ctx.Done():
+					return nil
+				}
+				if !d.ok {
+					return nil
+				}
+			}
+			ctx := ctx
+			if comp.opts.startTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, comp.opts.startTimeout)
+				defer cancel()
+			}
+			if err := comp.c.Start(ctx); err != nil {
+				return err
+			}
+			comp.ok = true
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// stopComponents stops the given components that started, each once the
+// components depending on it have been stopped.
+func stopComponents(ctx context.Context, batch []*component, deps map[*component][]*component) error {
+	dependents := make(map[*component][]*component)
+	for _, comp := range batch {
+		for _, d := range deps[comp] {
+			dependents[d] = append(dependents[d], comp)
+		}
+	}
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for _, comp := range batch {
+		comp := comp
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(comp.stopped)
+			for _, d := range dependents[comp] {
+				select {
+				case <-d.stopped:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-comp.started:
+			case <-ctx.Done():
+				return
+			}
+			if !comp.ok {
+				return
+			}
+			ctx := ctx
+			if comp.opts.stopTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, comp.opts.stopTimeout)
+				defer cancel()
+			}
+			if err := comp.c.Stop(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(errs) == 0 {
+		return ctx.Err()
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testComponent struct {
+	name     string
+	mu       *sync.Mutex
+	ops      *[]string
+	startErr error
+	block    bool
+}
+
+func (c *testComponent) Start(ctx context.Context) error {
+	if c.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.ops = append(*c.ops, "start "+c.name)
+	return c.startErr
+}
+
+func (c *testComponent) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.ops = append(*c.ops, "stop "+c.name)
+	return nil
+}
+
+func TestAdd(t *testing.T) {
+	var mu sync.Mutex
+	var ops []string
+	_, svc := NewService(context.Background())
+	db := &testComponent{name: "db", mu: &mu, ops: &ops}
+	cache := &testComponent{name: "cache", mu: &mu, ops: &ops}
+	server := &testComponent{name: "server", mu: &mu, ops: &ops, startErr: errors.New("test error")}
+	svc.Add(&testComponent{name: "never", mu: &mu, ops: &ops}, DependsOn(server))
+	svc.Add(server, DependsOn(cache))
+	svc.Add(cache, DependsOn(db))
+	svc.Add(db)
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+	want := []string{"start db", "start cache", "start server", "stop cache", "stop db"}
+	if len(ops) != len(want) {
+		t.Fatal("unexpected operations:", ops)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Fatal("unexpected operations:", ops)
+		}
+	}
+}
+
+// blockingComponent starts once every component in its group has been
+// asked to start, so it only starts if they are started concurrently.
+type blockingComponent struct {
+	wg *sync.WaitGroup
+}
+
+func (c blockingComponent) Start(ctx context.Context) error {
+	c.wg.Done()
+	c.wg.Wait()
+	return nil
+}
+
+func (c blockingComponent) Stop(ctx context.Context) error {
+	return nil
+}
+
+func TestAddConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(3)
+	_, svc := NewService(context.Background())
+	for i := 0; i < 3; i++ {
+		svc.Add(&blockingComponent{wg: &wg})
+	}
+	svc.Go(func() error {
+		<-svc.Ready()
+		svc.Shutdown(nil)
+		return nil
+	})
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestAddCycle(t *testing.T) {
+	_, svc := NewService(context.Background())
+	a := &testComponent{name: "a"}
+	b := &testComponent{name: "b"}
+	c := &testComponent{name: "c"}
+	svc.Add(a, DependsOn(b))
+	svc.Add(b, DependsOn(c))
+	expectMisuse(t, "Add", func() { svc.Add(c, DependsOn(a)) })
+	expectMisuse(t, "Add", func() { svc.Add(a) })
+}
+
+func TestAddStartTimeout(t *testing.T) {
+	var mu sync.Mutex
+	var ops []string
+	_, svc := NewService(context.Background())
+	svc.Add(&testComponent{name: "slow", mu: &mu, ops: &ops, block: true}, WithStartTimeout(time.Millisecond))
+	if err := svc.Wait(); err != context.DeadlineExceeded {
+		t.Error("unexpected error:", err)
+	}
+	if len(ops) != 0 {
+		t.Error("unexpected operations:", ops)
+	}
+}
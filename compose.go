@@ -0,0 +1,115 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import "sync"
+
+// A Composite is a number of independently constructed services run in
+// the same process. Each service keeps its own context and signals, so
+// one of the services shutting down, for example because its context was
+// canceled, does not stop the others.
+type Composite struct {
+	services []*Service
+
+	stopOnce sync.Once
+	stopC    chan struct{}
+	stopErr  error
+}
+
+// Compose creates a Composite from the given services.
+func Compose(services ...*Service) *Composite {
+	return &Composite{services: services, stopC: make(chan struct{})}
+}
+
+// Wait starts the services in the order they were given to Compose and
+// waits for all of them to complete. Each service is started, by calling
+// its Wait method, only once the previous service is ready, as reported
+// by its Ready method, so the functions registered with OnStart run one
+// service after another. If a service shuts down before it is ready the
+// remaining services are not started and the services already started
+// are shut down in reverse order, as they are by Shutdown.
+//
+// The error returned is the error from the first service, in the order
+// they were given to Compose, that was canceled with an error, if any.
+func (c *Composite) Wait() error {
+	errs := make([]error, len(c.services))
+	doneCs := make([]chan struct{}, len(c.services))
+	start := func(i int) {
+		doneCs[i] = make(chan struct{})
+		go func() {
+			defer close(doneCs[i])
+			errs[i] = c.services[i].Wait()
+		}()
+	}
+
+	started := 0
+	failed := false
+	var stopErr error
+	for i, s := range c.services {
+		start(i)
+		started++
+		select {
+		case <-s.Ready():
+			continue
+		case <-s.ShuttingDown():
+			select {
+			case <-s.Ready():
+				continue
+			default:
+			}
+		case <-c.stopC:
+			stopErr = c.stopErr
+		}
+		failed = true
+		break
+	}
+	if failed {
+		for i := started; i < len(c.services); i++ {
+			c.services[i].Shutdown(stopErr)
+			start(i)
+		}
+		c.stop(doneCs[:started], stopErr)
+	} else {
+		allDoneC := make(chan struct{})
+		go func() {
+			defer close(allDoneC)
+			for _, doneC := range doneCs {
+				<-doneC
+			}
+		}()
+		select {
+		case <-allDoneC:
+		case <-c.stopC:
+			c.stop(doneCs, c.stopErr)
+		}
+	}
+	for _, doneC := range doneCs {
+		<-doneC
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown shuts down the services in the reverse of the order they were
+// given to Compose, each once the later services have completed. If err
+// is not nil it is the error each service is shut down with. Shutdown
+// returns immediately; Wait waits for the services to complete.
+func (c *Composite) Shutdown(err error) {
+	c.stopOnce.Do(func() {
+		c.stopErr = err
+		close(c.stopC)
+	})
+}
+
+// stop shuts down the started services, whose Wait methods close the
+// given channels when they return, in reverse order.
+func (c *Composite) stop(doneCs []chan struct{}, err error) {
+	for i := len(doneCs) - 1; i >= 0; i-- {
+		c.services[i].Shutdown(err)
+		<-doneCs[i]
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCompose(t *testing.T) {
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+	_, svc1 := NewService(ctx1)
+	ctx2, svc2 := NewService(context.Background())
+
+	c := Compose(svc2, svc1)
+	errC := make(chan error)
+	go func() { errC <- c.Wait() }()
+	<-svc1.Ready()
+	svc2.Go(func() error {
+		return errors.New("test error")
+	})
+	<-ctx2.Done()
+	if err := ctx1.Err(); err != nil {
+		t.Fatal("first service stopped:", err)
+	}
+	cancel1()
+	if err := <-errC; err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestComposeOrder(t *testing.T) {
+	var mu sync.Mutex
+	var ops []string
+	record := func(op string) {
+		mu.Lock()
+		ops = append(ops, op)
+		mu.Unlock()
+	}
+	var services []*Service
+	for _, name := range []string{"db", "cache", "api"} {
+		name := name
+		_, svc := NewService(context.Background())
+		svc.OnStart(func(ctx context.Context) error {
+			// Give a later service the chance to start too early.
+			time.Sleep(10 * time.Millisecond)
+			record("start " + name)
+			return nil
+		})
+		svc.OnShutdown(func() { record("stop " + name) })
+		services = append(services, svc)
+	}
+	c := Compose(services...)
+	errC := make(chan error)
+	go func() { errC <- c.Wait() }()
+	<-services[2].Ready()
+	c.Shutdown(nil)
+	if err := <-errC; err != nil {
+		t.Error("unexpected error:", err)
+	}
+	want := "start db, start cache, start api, stop api, stop cache, stop db"
+	if got := strings.Join(ops, ", "); got != want {
+		t.Errorf("unexpected operations %q, expected %q", got, want)
+	}
+}
+
+func TestComposeStartFailure(t *testing.T) {
+	var started []string
+	_, svc1 := NewService(context.Background())
+	svc1.OnStart(func(ctx context.Context) error {
+		started = append(started, "db")
+		return nil
+	})
+	_, svc2 := NewService(context.Background())
+	svc2.OnStart(func(ctx context.Context) error {
+		return errors.New("test error")
+	})
+	_, svc3 := NewService(context.Background())
+	svc3.OnStart(func(ctx context.Context) error {
+		started = append(started, "api")
+		return nil
+	})
+	if err := Compose(svc1, svc2, svc3).Wait(); err == nil || err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+	if len(started) != 1 || started[0] != "db" {
+		t.Error("unexpected services started:", started)
+	}
+}
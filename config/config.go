@@ -0,0 +1,301 @@
+// Copyright 2021 Canonical Ltd.
+
+// Package config loads the configuration of a service into a struct from
+// defaults, a JSON file, environment variables and command line flags.
+//
+// The way each field is loaded is controlled by its struct tags:
+//
+//	type Config struct {
+//		Addr     string        `json:"addr" env:"ADDR" flag:"addr" default:":8080" usage:"listen address"`
+//		Timeout  time.Duration `json:"timeout" env:"TIMEOUT" default:"30s"`
+//		Password string        `json:"password" env:"PASSWORD" secret:"true"`
+//	}
+//
+// Values are applied in order of increasing precedence: the default tag,
+// the file, the environment and then the flags, so a flag given on the
+// command line overrides everything else. Fields marked as secret are
+// redacted by Redact, so that a configuration can be logged safely.
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Validator is a configuration that can check its own values. Load
+// calls Validate once all the values have been loaded.
+type Validator interface {
+	Validate() error
+}
+
+// A Loader loads configurations.
+type Loader struct {
+	// File is the path of a JSON file to load, decoded with
+	// encoding/json. If this is empty no file is loaded.
+	File string
+
+	// EnvPrefix is prepended to the names given in env tags.
+	EnvPrefix string
+
+	// Flags, if set, is the flag set the flags given in flag tags are
+	// defined on, and Args are the arguments it parses, normally
+	// os.Args[1:].
+	Flags *flag.FlagSet
+	Args  []string
+
+	// LookupEnv looks up environment variables. If this is nil
+	// os.LookupEnv is used.
+	LookupEnv func(string) (string, bool)
+
+	// Validate, if set, is called with the loaded configuration after
+	// its own Validate method, so that checks can be added without
+	// changing the configuration type, such as a schema shared by
+	// several services.
+	Validate func(cfg interface{}) error
+
+	parsed     bool
+	flagValues []flagValue
+}
+
+// A flagValue is a flag given on the command line.
+type flagValue struct {
+	name, value string
+}
+
+// parseFlags defines the flags for the given fields and parses the
+// arguments, the first time it is called. The flags are only parsed once
+// so that the configuration can be loaded again when it is reloaded.
+func (l *Loader) parseFlags(t reflect.Type, fields []int) error {
+	if l.parsed {
+		return nil
+	}
+	for _, i := range fields {
+		f := t.Field(i)
+		name, ok := f.Tag.Lookup("flag")
+		if !ok {
+			continue
+		}
+		l.Flags.Func(name, f.Tag.Get("usage"), func(s string) error {
+			l.flagValues = append(l.flagValues, flagValue{name: name, value: s})
+			return nil
+		})
+	}
+	if err := l.Flags.Parse(l.Args); err != nil {
+		return err
+	}
+	l.parsed = true
+	return nil
+}
+
+// Load loads the configuration into the struct pointed to by dst. If the
+// struct implements Validator, its Validate method is called once all the
+// values have been loaded, followed by the loader's Validate function if
+// it is set, and any error either returns is returned by Load.
+func (l *Loader) Load(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("config: destination must be a pointer to a struct")
+	}
+	v = v.Elem()
+	t := v.Type()
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			fields = append(fields, i)
+		}
+	}
+
+	for _, i := range fields {
+		if def, ok := t.Field(i).Tag.Lookup("default"); ok {
+			if err := set(v.Field(i), t.Field(i), def); err != nil {
+				return err
+			}
+		}
+	}
+
+	if l.File != "" {
+		buf, err := os.ReadFile(l.File)
+		if err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+		if err := json.Unmarshal(buf, dst); err != nil {
+			return fmt.Errorf("config: cannot parse %s: %w", l.File, err)
+		}
+	}
+
+	lookup := l.LookupEnv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	for _, i := range fields {
+		name, ok := t.Field(i).Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		if s, ok := lookup(l.EnvPrefix + name); ok {
+			if err := set(v.Field(i), t.Field(i), s); err != nil {
+				return err
+			}
+		}
+	}
+
+	if l.Flags != nil {
+		if err := l.parseFlags(t, fields); err != nil {
+			return err
+		}
+		for _, fv := range l.flagValues {
+			for _, i := range fields {
+				if t.Field(i).Tag.Get("flag") == fv.name {
+					if err := set(v.Field(i), t.Field(i), fv.value); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	if val, ok := dst.(Validator); ok {
+		if err := val.Validate(); err != nil {
+			return fmt.Errorf("config: invalid configuration: %w", err)
+		}
+	}
+	if l.Validate != nil {
+		if err := l.Validate(dst); err != nil {
+			return fmt.Errorf("config: invalid configuration: %w", err)
+		}
+	}
+	return nil
+}
+
+// ValidateOnly loads and validates a configuration of type T with the
+// given loader, through exactly the same code as the service uses at
+// startup, without starting anything. It is intended for a
+// --validate-config flag, so that deployments and CI can check a
+// configuration before it is used. The context is checked before the
+// configuration is loaded, so that a canceled check does no work.
+func ValidateOnly[T any](ctx context.Context, l *Loader) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return l.Load(new(T))
+}
+
+// Diff returns the names of the fields that differ between two
+// configurations of the same struct type, or pointers to them, in
+// field order. Fields are named as by Redact.
+func Diff(old, new interface{}) []string {
+	ov := reflect.Indirect(reflect.ValueOf(old))
+	nv := reflect.Indirect(reflect.ValueOf(new))
+	if ov.Kind() != reflect.Struct || ov.Type() != nv.Type() {
+		return nil
+	}
+	t := ov.Type()
+	var changed []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.IsExported() && !reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			changed = append(changed, fieldName(f))
+		}
+	}
+	return changed
+}
+
+// fieldName returns the name of a configuration field, taken from its
+// json tag if it has one.
+func fieldName(f reflect.StructField) string {
+	if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag != "" && tag != "-" {
+		return tag
+	}
+	return f.Name
+}
+
+// set parses s into the given field.
+func set(v reflect.Value, f reflect.StructField, s string) error {
+	fail := func(err error) error {
+		return fmt.Errorf("config: invalid value for %s: %w", f.Name, err)
+	}
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fail(err)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fail(err)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return fail(err)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return fail(err)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fail(err)
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fail(errors.New("unsupported type " + v.Type().String()))
+		}
+		var parts []string
+		if s != "" {
+			parts = strings.Split(s, ",")
+		}
+		v.Set(reflect.ValueOf(parts).Convert(v.Type()))
+	default:
+		return fail(errors.New("unsupported type " + v.Type().String()))
+	}
+	return nil
+}
+
+// Redact returns a slog.Value of the given configuration struct, or
+// pointer to one, for logging, in which the values of fields marked as
+// secret are replaced by "REDACTED". Unset secret fields are left empty,
+// so that the log shows whether a secret was provided.
+func Redact(cfg interface{}) slog.Value {
+	v := reflect.Indirect(reflect.ValueOf(cfg))
+	if v.Kind() != reflect.Struct {
+		return slog.AnyValue(cfg)
+	}
+	t := v.Type()
+	var attrs []slog.Attr
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := fieldName(f)
+		field := v.Field(i)
+		if secret, _ := strconv.ParseBool(f.Tag.Get("secret")); secret && !field.IsZero() {
+			attrs = append(attrs, slog.String(name, "REDACTED"))
+			continue
+		}
+		attrs = append(attrs, slog.Any(name, field.Interface()))
+	}
+	return slog.GroupValue(attrs...)
+}
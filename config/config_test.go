@@ -0,0 +1,134 @@
+// Copyright 2021 Canonical Ltd.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Addr     string        `json:"addr" env:"ADDR" flag:"addr" default:":8080"`
+	Timeout  time.Duration `json:"timeout" env:"TIMEOUT" default:"30s"`
+	Workers  int           `json:"workers" env:"WORKERS" flag:"workers" default:"4"`
+	Tags     []string      `json:"tags" env:"TAGS"`
+	Password string        `json:"password" env:"PASSWORD" secret:"true"`
+	Token    string        `json:"token" secret:"true"`
+}
+
+func (c *testConfig) Validate() error {
+	if c.Workers <= 0 {
+		return errors.New("workers must be positive")
+	}
+	return nil
+}
+
+func TestLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(`{"addr": ":9000", "workers": 8, "timeout": 5000000000}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"APP_WORKERS":  "16",
+		"APP_TAGS":     "a,b",
+		"APP_PASSWORD": "hunter2",
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := &Loader{
+		File:      file,
+		EnvPrefix: "APP_",
+		Flags:     fs,
+		Args:      []string{"-workers", "32"},
+		LookupEnv: func(name string) (string, bool) {
+			v, ok := env[name]
+			return v, ok
+		},
+	}
+	var cfg testConfig
+	if err := l.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != ":9000" || cfg.Timeout != 5*time.Second || cfg.Workers != 32 ||
+		strings.Join(cfg.Tags, " ") != "a b" || cfg.Password != "hunter2" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	l := &Loader{LookupEnv: func(name string) (string, bool) {
+		if name == "WORKERS" {
+			return "0", true
+		}
+		return "", false
+	}}
+	var cfg testConfig
+	err := l.Load(&cfg)
+	if err == nil || err.Error() != "config: invalid configuration: workers must be positive" {
+		t.Error("unexpected error:", err)
+	}
+
+	l.LookupEnv = func(name string) (string, bool) {
+		return "x", name == "TIMEOUT"
+	}
+	if err := l.Load(&cfg); err == nil || !strings.HasPrefix(err.Error(), "config: invalid value for Timeout") {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestValidateOnly(t *testing.T) {
+	env := map[string]string{"WORKERS": "0"}
+	l := &Loader{LookupEnv: func(k string) (string, bool) { v, ok := env[k]; return v, ok }}
+	err := ValidateOnly[testConfig](context.Background(), l)
+	if err == nil || err.Error() != "config: invalid configuration: workers must be positive" {
+		t.Error("unexpected error:", err)
+	}
+
+	env["WORKERS"] = "2"
+	l.Validate = func(cfg interface{}) error {
+		if cfg.(*testConfig).Addr == ":8080" {
+			return errors.New("default address not allowed")
+		}
+		return nil
+	}
+	err = ValidateOnly[testConfig](context.Background(), l)
+	if err == nil || err.Error() != "config: invalid configuration: default address not allowed" {
+		t.Error("unexpected error:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ValidateOnly[testConfig](ctx, l); err != context.Canceled {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := testConfig{Addr: ":80", Workers: 2, Tags: []string{"a"}}
+	new := testConfig{Addr: ":80", Workers: 3, Tags: []string{"a", "b"}}
+	changed := Diff(&old, new)
+	if len(changed) != 2 || changed[0] != "workers" || changed[1] != "tags" {
+		t.Error("unexpected changed fields:", changed)
+	}
+	if changed := Diff(old, old); len(changed) != 0 {
+		t.Error("unexpected changed fields:", changed)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	var buf strings.Builder
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	cfg := testConfig{Addr: ":80", Password: "hunter2"}
+	logger.Info("loaded", "config", Redact(&cfg))
+	out := buf.String()
+	if strings.Contains(out, "hunter2") || !strings.Contains(out, "config.password=REDACTED") ||
+		!strings.Contains(out, "config.token=\"\"") || !strings.Contains(out, "config.addr=:80") {
+		t.Error("unexpected output:", out)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2021 Canonical Ltd.
+
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/canonical/go-service"
+)
+
+// A Watcher holds the current configuration of a service, reloading it
+// on request. A configuration that fails to load or validate is
+// discarded, leaving the previous configuration in place.
+type Watcher[T any] struct {
+	load    func() (*T, error)
+	current atomic.Pointer[T]
+
+	mu          sync.Mutex
+	subscribers []func(*T)
+
+	// PollInterval is the time between checks for changes to the files
+	// watched by Watch. If this is zero or negative the files are checked
+	// every five seconds.
+	PollInterval time.Duration
+
+	// OnError, if set, is called with the errors reloading the
+	// configuration when it is reloaded by Watch.
+	OnError func(error)
+
+	// OnReload, if set, is called after each successful reload with the
+	// names of the fields that changed, as reported by Diff.
+	OnReload func(changed []string)
+
+	// Logger, if set, logs each reload, with the names of the fields
+	// that changed, and the errors reloading the configuration.
+	Logger *slog.Logger
+}
+
+// NewWatcher returns a Watcher for the configuration loaded by the given
+// function, which is called immediately to load the initial
+// configuration.
+func NewWatcher[T any](load func() (*T, error)) (*Watcher[T], error) {
+	w := &Watcher[T]{load: load}
+	cfg, err := load()
+	if err != nil {
+		return nil, err
+	}
+	w.current.Store(cfg)
+	return w, nil
+}
+
+// LoadFunc returns a function loading a new configuration with the given
+// loader, for use with NewWatcher.
+func LoadFunc[T any](l *Loader) func() (*T, error) {
+	return func() (*T, error) {
+		cfg := new(T)
+		if err := l.Load(cfg); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+}
+
+// Get returns the current configuration, which must not be modified.
+func (w *Watcher[T]) Get() *T {
+	return w.current.Load()
+}
+
+// Subscribe registers a function to be called with the new configuration
+// each time it is reloaded successfully.
+func (w *Watcher[T]) Subscribe(f func(*T)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, f)
+}
+
+// Reload loads the configuration again. If it loads and validates
+// successfully it replaces the current configuration, the changed fields
+// are reported to OnReload and the Logger, and the subscribers are
+// notified; otherwise the current configuration is kept and the error is
+// returned.
+func (w *Watcher[T]) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	cfg, err := w.load()
+	if err != nil {
+		if w.Logger != nil {
+			w.Logger.Error("configuration reload failed", "error", err)
+		}
+		return err
+	}
+	changed := Diff(w.current.Load(), cfg)
+	w.current.Store(cfg)
+	if w.Logger != nil {
+		w.Logger.Info("configuration reloaded", "changed", changed)
+	}
+	if w.OnReload != nil {
+		w.OnReload(changed)
+	}
+	for _, f := range w.subscribers {
+		f(cfg)
+	}
+	return nil
+}
+
+// pollInterval returns the time between checks for changes to the files.
+func (w *Watcher[T]) pollInterval() time.Duration {
+	if w.PollInterval <= 0 {
+		return 5 * time.Second
+	}
+	return w.PollInterval
+}
+
+// Watch reloads the configuration, in goroutines of the given service,
+// whenever the given signal is received, if it is not nil, and whenever
+// the modification time of one of the given files changes. Watching stops
+// when the service starts shutting down.
+func (w *Watcher[T]) Watch(svc *service.Service, sig os.Signal, files ...string) {
+	reload := func() {
+		if err := w.Reload(); err != nil && w.OnError != nil {
+			w.OnError(err)
+		}
+	}
+	if sig != nil {
+		svc.OnSignal(sig, func(context.Context) error {
+			reload()
+			return nil
+		})
+	}
+	if len(files) == 0 {
+		return
+	}
+	interval := w.pollInterval()
+	modTimes := make([]time.Time, len(files))
+	stat := func() bool {
+		changed := false
+		for i, file := range files {
+			var t time.Time
+			if fi, err := os.Stat(file); err == nil {
+				t = fi.ModTime()
+			}
+			if !t.Equal(modTimes[i]) {
+				modTimes[i] = t
+				changed = true
+			}
+		}
+		return changed
+	}
+	stat()
+	svc.GoEvery(interval, func(context.Context) error {
+		if stat() {
+			reload()
+		}
+		return nil
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 Canonical Ltd.
+
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-service"
+)
+
+func TestWatcher(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	write := func(s string, mtime time.Time) {
+		if err := os.WriteFile(file, []byte(s), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	write(`{"workers": 2}`, now)
+
+	w, err := NewWatcher(LoadFunc[testConfig](&Loader{File: file}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Get().Workers != 2 {
+		t.Errorf("unexpected config: %+v", w.Get())
+	}
+	w.PollInterval = time.Millisecond
+	reloaded := make(chan int, 10)
+	errs := make(chan error, 10)
+	w.Subscribe(func(cfg *testConfig) { reloaded <- cfg.Workers })
+	w.OnError = func(err error) { errs <- err }
+	changes := make(chan []string, 10)
+	w.OnReload = func(changed []string) { changes <- changed }
+
+	_, svc := service.NewService(context.Background())
+	w.Watch(svc, nil, file)
+	write(`{"workers": 0}`, now.Add(time.Second))
+	if err := <-errs; err.Error() != "config: invalid configuration: workers must be positive" {
+		t.Error("unexpected error:", err)
+	}
+	if w.Get().Workers != 2 {
+		t.Errorf("invalid config not rolled back: %+v", w.Get())
+	}
+	write(`{"workers": 3}`, now.Add(2*time.Second))
+	if changed := <-changes; len(changed) != 1 || changed[0] != "workers" {
+		t.Error("unexpected changed fields:", changed)
+	}
+	if n := <-reloaded; n != 3 {
+		t.Error("unexpected workers:", n)
+	}
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestWatcherPollInterval(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		want     time.Duration
+	}{
+		{0, 5 * time.Second},
+		{-time.Second, 5 * time.Second},
+		{time.Millisecond, time.Millisecond},
+	}
+	for _, test := range tests {
+		w := &Watcher[testConfig]{PollInterval: test.interval}
+		if got := w.pollInterval(); got != test.want {
+			t.Errorf("poll interval %v gave %v, expected %v", test.interval, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GoCron runs the given job in a goroutine of the service at the times
+// given by a cron expression, until the service starts shutting down.
+// The expression has the five standard fields, minute, hour, day of
+// month, month and day of week, each of which may be "*", a number, a
+// range, a list, or have a step, as in "*/15" or "1-5". Months and days
+// of the week may also be given by their three letter English names.
+// The expressions "@yearly", "@monthly", "@weekly", "@daily" and
+// "@hourly" are also accepted. As in cron, if both the day of month and
+// the day of week are restricted, the job runs on days matching either.
+//
+// Times are in the local time zone. A job scheduled at a time skipped
+// when the clocks go forward does not run that day, and a job scheduled
+// at a time repeated when the clocks go back only runs once. A job in
+// progress when the service shuts down is passed the service's context,
+// and is waited for. The options are those accepted by GoEvery; unless
+// WithRunErrorHandler is used an error from the job causes the service
+// to shut down.
+func (s *Service) GoCron(spec string, job func(ctx context.Context) error, opts ...EveryOption) error {
+	if s.opts.strict && job == nil {
+		misuse("GoCron", "called with a nil function")
+	}
+	sched, err := parseCron(spec)
+	if err != nil {
+		return err
+	}
+	var o everyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	s.Go(func() error {
+		run := func() error {
+			err := job(s.ctx)
+			if err != nil && o.onError != nil {
+				o.onError(err)
+				return nil
+			}
+			return err
+		}
+		if o.immediate {
+			if err := run(); err != nil {
+				return err
+			}
+		}
+		for {
+			next := sched.next(time.Now())
+			if next.IsZero() {
+				return nil
+			}
+			t := time.NewTimer(time.Until(next) + jitter(o.jitter))
+			select {
+			case <-s.doneC:
+				t.Stop()
+				return nil
+			case <-t.C:
+			}
+			if s.IsShuttingDown() {
+				return nil
+			}
+			if err := run(); err != nil {
+				return err
+			}
+		}
+	})
+	return nil
+}
+
+// A cronSchedule is a parsed cron expression. Each field is a bit set of
+// the values it matches.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// cronMacros are the cron expressions that can be given by name.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+	dayNames   = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+)
+
+// parseCron parses a cron expression.
+func parseCron(spec string) (*cronSchedule, error) {
+	expr := spec
+	if m, ok := cronMacros[strings.ToLower(spec)]; ok {
+		expr = m
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields", spec)
+	}
+	var c cronSchedule
+	var err error
+	parse := func(dst *uint64, field string, min, max int, names []string, nameBase int) bool {
+		if err != nil {
+			return false
+		}
+		*dst, err = parseCronField(field, min, max, names, nameBase)
+		if err != nil {
+			err = fmt.Errorf("invalid cron expression %q: %v", spec, err)
+		}
+		return field == "*" || strings.HasPrefix(field, "*/")
+	}
+	parse(&c.minute, fields[0], 0, 59, nil, 0)
+	parse(&c.hour, fields[1], 0, 23, nil, 0)
+	c.domStar = parse(&c.dom, fields[2], 1, 31, nil, 0)
+	parse(&c.month, fields[3], 1, 12, monthNames, 1)
+	c.dowStar = parse(&c.dow, fields[4], 0, 7, dayNames, 0)
+	if err != nil {
+		return nil, err
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return &c, nil
+}
+
+// parseCronField parses one field of a cron expression into a bit set.
+func parseCronField(field string, min, max int, names []string, nameBase int) (uint64, error) {
+	value := func(s string) (int, error) {
+		for i, name := range names {
+			if strings.EqualFold(s, name) {
+				return i + nameBase, nil
+			}
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("invalid value %q", s)
+		}
+		return n, nil
+	}
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			rng, step = part[:i], n
+		}
+		lo, hi := min, max
+		switch i := strings.Index(rng, "-"); {
+		case rng == "*":
+		case i >= 0:
+			var err error
+			if lo, err = value(rng[:i]); err != nil {
+				return 0, err
+			}
+			if hi, err = value(rng[i+1:]); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		default:
+			var err error
+			if lo, err = value(rng); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if step > 1 {
+				hi = max
+			}
+		}
+		for n := lo; n <= hi; n += step {
+			bits |= 1 << n
+		}
+	}
+	return bits, nil
+}
+
+// next returns the first time after t matching the schedule, or the zero
+// time if there is none within the next five years.
+func (c *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	from := wallClock(t)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + 5
+	for t.Year() <= limit {
+		y, m, d := t.Date()
+		switch {
+		case c.month&(1<<uint(m)) == 0:
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case c.minute&(1<<uint(t.Minute())) == 0, !wallClock(t).After(from):
+			// Times repeated when the clocks go back are skipped, so
+			// that jobs do not run twice.
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// wallClock returns the wall clock time of t, ignoring its time zone.
+func wallClock(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+// dayMatches reports whether the day of t matches the schedule.
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		spec string
+		from string
+		want string
+	}{
+		{"0 3 * * *", "2021-06-01 12:00", "2021-06-02 03:00"},
+		{"*/15 * * * *", "2021-06-01 12:07", "2021-06-01 12:15"},
+		{"30 9 * * mon-fri", "2021-06-04 10:00", "2021-06-07 09:30"},
+		{"0 0 1,15 * *", "2021-06-02 00:00", "2021-06-15 00:00"},
+		{"0 0 13 * 5", "2021-06-02 00:00", "2021-06-04 00:00"},
+		{"@monthly", "2021-12-31 23:59", "2022-01-01 00:00"},
+		{"0 0 29 feb *", "2021-03-01 00:00", "2024-02-29 00:00"},
+		{"30 1 * * *", "2021-03-27 12:00", "2021-03-29 01:30"},
+		{"30 1 * * *", "2021-10-31 00:45", "2021-10-31 01:30"},
+		{"30 1 * * *", "2021-10-31 01:31", "2021-11-01 01:30"},
+	}
+	for _, test := range tests {
+		c, err := parseCron(test.spec)
+		if err != nil {
+			t.Errorf("%s: %v", test.spec, err)
+			continue
+		}
+		from, _ := time.ParseInLocation("2006-01-02 15:04", test.from, loc)
+		if got := c.next(from).Format("2006-01-02 15:04"); got != test.want {
+			t.Errorf("%s from %s: got %s, want %s", test.spec, test.from, got, test.want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, spec := range []string{"* * * *", "60 * * * *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("%s: expected error", spec)
+		}
+	}
+}
+
+func TestGoCron(t *testing.T) {
+	_, svc := NewService(context.Background())
+	if err := svc.GoCron("bad", func(context.Context) error { return nil }); err == nil {
+		t.Error("expected error")
+	}
+	ran := make(chan struct{}, 1)
+	if err := svc.GoCron("@yearly", func(context.Context) error {
+		ran <- struct{}{}
+		return nil
+	}, WithImmediateRun()); err != nil {
+		t.Fatal(err)
+	}
+	<-ran
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
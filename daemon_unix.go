@@ -0,0 +1,88 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package service
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// daemonEnv is the environment variable used to tell a re-executed
+// process that it is the daemon.
+const daemonEnv = "_GO_SERVICE_DAEMON"
+
+// A DaemonConfig configures Daemonize.
+type DaemonConfig struct {
+	// Foreground disables daemonizing, so that Daemonize returns
+	// immediately. This is normally set from a --foreground flag.
+	Foreground bool
+
+	// Dir is the working directory of the daemon. If this is empty "/"
+	// is used.
+	Dir string
+
+	// Stdout and Stderr are the paths of files the standard output and
+	// standard error of the daemon are appended to. If either is empty
+	// that output is discarded.
+	Stdout string
+	Stderr string
+}
+
+// Daemonize runs the program as a classic unix daemon, detached from its
+// controlling terminal in a new session with its standard input
+// redirected from /dev/null. Daemonize should be called at the start of
+// main, before any services are created.
+//
+// The Go runtime cannot safely fork, so the program is started again with
+// the same arguments in the background and the original process exits
+// with status 0 once the daemon has started. Daemonize returns nil in the
+// daemon, and an error in the original process if the daemon could not
+// be started.
+func Daemonize(cfg DaemonConfig) error {
+	if cfg.Foreground {
+		return nil
+	}
+	if os.Getenv(daemonEnv) != "" {
+		return os.Unsetenv(daemonEnv)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	stdout, err := openDaemonOutput(cfg.Stdout)
+	if err != nil {
+		return err
+	}
+	defer stdout.Close()
+	stderr, err := openDaemonOutput(cfg.Stderr)
+	if err != nil {
+		return err
+	}
+	defer stderr.Close()
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Dir = cfg.Dir
+	if cmd.Dir == "" {
+		cmd.Dir = "/"
+	}
+	cmd.Env = append(os.Environ(), daemonEnv+"=1")
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
+
+func openDaemonOutput(path string) (*os.File, error) {
+	if path == "" {
+		return os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+}
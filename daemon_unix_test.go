@@ -0,0 +1,27 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package service
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDaemonizeForeground(t *testing.T) {
+	if err := Daemonize(DaemonConfig{Foreground: true}); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestDaemonizeInDaemon(t *testing.T) {
+	os.Setenv(daemonEnv, "1")
+	if err := Daemonize(DaemonConfig{}); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if v, ok := os.LookupEnv(daemonEnv); ok {
+		t.Errorf("%s still set to %q", daemonEnv, v)
+	}
+}
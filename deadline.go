@@ -0,0 +1,50 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// WithWorkDeadline returns a context derived from the given one for
+// handling a single item of work, such as a message or request, that
+// expires after the given duration or when the service's time for the
+// work runs out, whichever is sooner. The service's time runs out when
+// its context is canceled, which is after any grace period set with
+// WithShutdownGrace, or when the shutdown budget set with
+// WithShutdownTimeout runs out, even when the given context is not
+// derived from the service's, in which case context.Cause reports
+// context.DeadlineExceeded. If d is zero or negative only the service's
+// deadlines apply.
+//
+// Canceling the returned context releases the resources associated with
+// it, so the cancel function should be called as soon as the work is
+// done.
+func (s *Service) WithWorkDeadline(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	cancelTimeout := func() {}
+	if d > 0 {
+		ctx, cancelTimeout = context.WithTimeout(ctx, d)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	stopSoft := context.AfterFunc(s.ctx, func() {
+		cancel(context.Cause(s.ctx))
+	})
+	stopHard := context.AfterFunc(s.groupCtx, func() {
+		s.budget.begin()
+		deadline, ok := s.budget.Deadline()
+		if !ok {
+			return
+		}
+		t := time.AfterFunc(time.Until(deadline), func() {
+			cancel(context.DeadlineExceeded)
+		})
+		context.AfterFunc(ctx, func() { t.Stop() })
+	})
+	return ctx, func() {
+		stopSoft()
+		stopHard()
+		cancel(context.Canceled)
+		cancelTimeout()
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithWorkDeadline(t *testing.T) {
+	_, svc := NewService(context.Background())
+	ctx, cancel := svc.WithWorkDeadline(context.Background(), time.Millisecond)
+	<-ctx.Done()
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Error("unexpected error:", ctx.Err())
+	}
+	cancel()
+
+	ctx, cancel = svc.WithWorkDeadline(context.Background(), time.Hour)
+	defer cancel()
+	svc.Shutdown(nil)
+	<-ctx.Done()
+	if ctx.Err() != context.Canceled {
+		t.Error("unexpected error:", ctx.Err())
+	}
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestWithWorkDeadlineBudget(t *testing.T) {
+	ctx, svc := New(context.Background(), WithShutdownGrace(time.Hour), WithShutdownTimeout(10*time.Millisecond))
+	work, cancel := svc.WithWorkDeadline(context.Background(), time.Hour)
+	defer cancel()
+	svc.Shutdown(nil)
+	<-work.Done()
+	if work.Err() != context.Canceled || context.Cause(work) != context.DeadlineExceeded {
+		t.Error("unexpected error:", work.Err(), context.Cause(work))
+	}
+	if ctx.Err() != nil {
+		t.Error("service context canceled before the grace period")
+	}
+	svc.Wait()
+}
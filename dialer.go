@@ -0,0 +1,139 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// A ConnState is the state of connectivity to an address dialed by a
+// Dialer.
+type ConnState int
+
+const (
+	// ConnUnknown is the state of an address that has not been dialed.
+	ConnUnknown ConnState = iota
+
+	// ConnUp is the state of an address that was last dialed
+	// successfully.
+	ConnUp
+
+	// ConnDown is the state of an address that last failed to dial.
+	ConnDown
+)
+
+// String implements fmt.Stringer.
+func (s ConnState) String() string {
+	switch s {
+	case ConnUp:
+		return "up"
+	case ConnDown:
+		return "down"
+	default:
+		return "unknown"
+	}
+}
+
+// A Dialer dials connections to the dependencies of a service, retrying
+// failed attempts with exponential backoff until the dial succeeds or its
+// context is done. The same Dialer therefore handles a dependency that is
+// unavailable when the service starts and one that becomes unavailable
+// while the service is running.
+//
+// A Dialer is safe for concurrent use.
+type Dialer struct {
+	// Dialer is used to make each connection attempt. If this is nil a
+	// zero net.Dialer is used.
+	Dialer *net.Dialer
+
+	// MinBackoff is the delay after the first failed attempt, which
+	// doubles after every subsequent failure. If this is zero or
+	// negative 100ms is used.
+	MinBackoff time.Duration
+
+	// MaxBackoff is the maximum delay between attempts. If this is zero
+	// or negative 30s is used.
+	MaxBackoff time.Duration
+
+	// MaxAttempts is the maximum number of attempts made by each dial. If
+	// this is zero attempts are made until the context is done.
+	MaxAttempts int
+
+	// OnStateChange, if set, is called whenever the connectivity state of
+	// an address changes. The error is the reason an address went down.
+	OnStateChange func(network, address string, state ConnState, err error)
+
+	mu     sync.Mutex
+	states map[string]ConnState
+}
+
+// DialContext connects to the address on the named network, retrying as
+// necessary. If the dial does not succeed the last error from an attempt
+// is returned, or the context error if no attempt was made.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	nd := d.Dialer
+	if nd == nil {
+		nd = new(net.Dialer)
+	}
+	backoff := d.MinBackoff
+	if backoff <= 0 {
+		backoff = 100 * time.Millisecond
+	}
+	maxBackoff := d.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for attempt := 1; ; attempt++ {
+		conn, err := nd.DialContext(ctx, network, address)
+		if err == nil {
+			d.setState(network, address, ConnUp, nil)
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		d.setState(network, address, ConnDown, err)
+		if d.MaxAttempts > 0 && attempt >= d.MaxAttempts {
+			return nil, err
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, err
+		case <-t.C:
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// State returns the current connectivity state of the given address.
+func (d *Dialer) State(network, address string) ConnState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.states[network+" "+address]
+}
+
+func (d *Dialer) setState(network, address string, state ConnState, err error) {
+	d.mu.Lock()
+	key := network + " " + address
+	changed := d.states[key] != state
+	if d.states == nil {
+		d.states = make(map[string]ConnState)
+	}
+	d.states[key] = state
+	d.mu.Unlock()
+	if changed && d.OnStateChange != nil {
+		d.OnStateChange(network, address, state, err)
+	}
+}
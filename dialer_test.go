@@ -0,0 +1,89 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDialerRetries(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	var states []ConnState
+	d := &Dialer{
+		MinBackoff:  time.Millisecond,
+		MaxAttempts: 3,
+		OnStateChange: func(network, address string, state ConnState, err error) {
+			states = append(states, state)
+		},
+	}
+	if _, err := d.DialContext(context.Background(), "tcp", addr); err == nil {
+		t.Fatal("expected error")
+	}
+	if d.State("tcp", addr) != ConnDown {
+		t.Error("unexpected state:", d.State("tcp", addr))
+	}
+
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip("cannot rebind address:", err)
+	}
+	defer l.Close()
+	conn, err := d.DialContext(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	conn.Close()
+	if len(states) != 2 || states[0] != ConnDown || states[1] != ConnUp {
+		t.Error("unexpected state changes:", states)
+	}
+}
+
+func TestDialerContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d := new(Dialer)
+	if _, err := d.DialContext(ctx, "tcp", "127.0.0.1:1"); err != context.Canceled {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestDialerNegativeBackoff(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	tests := []struct {
+		d   *Dialer
+		min time.Duration
+	}{{
+		// The default MinBackoff of 100ms is used.
+		d:   &Dialer{MinBackoff: -time.Second, MaxAttempts: 2},
+		min: 100 * time.Millisecond,
+	}, {
+		// The delays are 20ms then 40ms, limited by the default
+		// MaxBackoff rather than by the negative value.
+		d:   &Dialer{MinBackoff: 20 * time.Millisecond, MaxBackoff: -time.Second, MaxAttempts: 3},
+		min: 60 * time.Millisecond,
+	}}
+	for _, test := range tests {
+		start := time.Now()
+		if _, err := test.d.DialContext(context.Background(), "tcp", addr); err == nil {
+			t.Fatal("expected error")
+		}
+		if elapsed := time.Since(start); elapsed < test.min {
+			t.Errorf("%+v: attempts took %v, expected at least %v", test.d, elapsed, test.min)
+		}
+	}
+}
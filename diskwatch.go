@@ -0,0 +1,144 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// DiskUsage reports the space and inodes available on the file system
+// containing a path.
+type DiskUsage struct {
+	Path        string
+	Free        uint64
+	Total       uint64
+	FreeInodes  uint64
+	TotalInodes uint64
+}
+
+// FreeFraction returns the fraction of the space on the file system that
+// is available.
+func (u DiskUsage) FreeFraction() float64 {
+	if u.Total == 0 {
+		return 1
+	}
+	return float64(u.Free) / float64(u.Total)
+}
+
+// FreeInodeFraction returns the fraction of the inodes on the file system
+// that are available. File systems that do not report their inodes are
+// treated as having them all available.
+func (u DiskUsage) FreeInodeFraction() float64 {
+	if u.TotalInodes == 0 {
+		return 1
+	}
+	return float64(u.FreeInodes) / float64(u.TotalInodes)
+}
+
+// A DiskWatcher monitors the free space and inodes on the file systems
+// containing a number of paths, such as the directories a service writes
+// spool or journal files to. A DiskWatcher is normally run as one of the
+// goroutines of a service:
+//
+//	svc.Go(func() error { return w.Run(ctx) })
+type DiskWatcher struct {
+	// Paths are the paths to watch.
+	Paths []string
+
+	// Interval is the time between checks. If this is zero or negative the
+	// paths are checked every 30 seconds.
+	Interval time.Duration
+
+	// MinFree and MinFreeInodes are the fractions of the space and inodes
+	// that must be available on a file system for it not to be low on
+	// space. If either is zero the corresponding check is disabled.
+	MinFree       float64
+	MinFreeInodes float64
+
+	// OnLow, if set, is called when a path's file system becomes low on
+	// space or inodes, and OnRecovered, if set, is called once it is no
+	// longer low on either.
+	OnLow       func(DiskUsage)
+	OnRecovered func(DiskUsage)
+
+	// SetReadOnly, if set, is called to switch to read-only mode while
+	// any of the paths is low on space or inodes.
+	SetReadOnly func(on bool, reason string)
+
+	// OnError, if set, is called for each path that cannot be checked.
+	OnError func(error)
+}
+
+// NewDiskWatcher returns a DiskWatcher watching the given paths which
+// switches the service to read-only mode while any of them is low on
+// space or inodes.
+func (s *Service) NewDiskWatcher(paths ...string) *DiskWatcher {
+	return &DiskWatcher{
+		Paths:       paths,
+		SetReadOnly: s.SetReadOnly,
+	}
+}
+
+// Run checks the paths until the given context is done. Run always
+// returns nil, with errors checking the paths reported to OnError.
+func (w *DiskWatcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	low := make(map[string]bool)
+	check := func() {
+		for _, path := range w.Paths {
+			u, err := diskUsage(path)
+			if err != nil {
+				if w.OnError != nil {
+					w.OnError(err)
+				}
+				continue
+			}
+			isLow := w.MinFree > 0 && u.FreeFraction() < w.MinFree ||
+				w.MinFreeInodes > 0 && u.FreeInodeFraction() < w.MinFreeInodes
+			if isLow == low[path] {
+				continue
+			}
+			wasLow := len(low) > 0
+			if isLow {
+				low[path] = true
+				if w.OnLow != nil {
+					w.OnLow(u)
+				}
+			} else {
+				delete(low, path)
+				if w.OnRecovered != nil {
+					w.OnRecovered(u)
+				}
+			}
+			if w.SetReadOnly == nil {
+				continue
+			}
+			switch {
+			case isLow && !wasLow:
+				w.SetReadOnly(true, "low on disk space at "+path)
+			case !isLow && len(low) == 0:
+				w.SetReadOnly(false, "disk space recovered at "+path)
+			}
+		}
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	check()
+	for {
+		select {
+		case <-t.C:
+			check()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// diskUsage is a variable so that tests can simulate a file system
+// running out of space.
+var diskUsage = statDiskUsage
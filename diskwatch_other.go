@@ -0,0 +1,14 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux
+// +build !darwin,!dragonfly,!freebsd,!linux
+
+package service
+
+import "errors"
+
+// statDiskUsage returns the usage of the file system containing the given
+// path. Disk usage is not supported on this platform.
+func statDiskUsage(path string) (DiskUsage, error) {
+	return DiskUsage{}, errors.New("disk usage is not supported on this platform")
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDiskWatcher(t *testing.T) {
+	var mu sync.Mutex
+	free := uint64(50)
+	defer func(f func(string) (DiskUsage, error)) { diskUsage = f }(diskUsage)
+	diskUsage = func(path string) (DiskUsage, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return DiskUsage{Path: path, Free: free, Total: 100}, nil
+	}
+	setFree := func(n uint64) {
+		mu.Lock()
+		free = n
+		mu.Unlock()
+	}
+
+	ctx, svc := NewService(context.Background())
+	events := make(chan string, 10)
+	w := svc.NewDiskWatcher("/spool")
+	w.Interval = time.Millisecond
+	w.MinFree = 0.1
+	w.OnLow = func(u DiskUsage) { events <- "low " + u.Path }
+	w.OnRecovered = func(u DiskUsage) { events <- "recovered " + u.Path }
+	svc.Go(func() error { return w.Run(ctx) })
+
+	setFree(5)
+	if ev := <-events; ev != "low /spool" {
+		t.Error("unexpected event:", ev)
+	}
+	if on, reason := svc.ReadOnly(); !on || reason != "low on disk space at /spool" {
+		t.Error("unexpected read-only state:", on, reason)
+	}
+	setFree(50)
+	if ev := <-events; ev != "recovered /spool" {
+		t.Error("unexpected event:", ev)
+	}
+	if on, _ := svc.ReadOnly(); on {
+		t.Error("service still read-only")
+	}
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestDiskWatcherNegativeInterval(t *testing.T) {
+	defer func(f func(string) (DiskUsage, error)) { diskUsage = f }(diskUsage)
+	diskUsage = func(path string) (DiskUsage, error) {
+		return DiskUsage{Path: path, Free: 50, Total: 100}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := &DiskWatcher{Paths: []string{"/spool"}, Interval: -time.Second}
+	if err := w.Run(ctx); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestStatDiskUsage(t *testing.T) {
+	u, err := statDiskUsage(t.TempDir())
+	if err != nil {
+		t.Skip(err)
+	}
+	if u.Total == 0 || u.Free > u.Total {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build darwin || dragonfly || freebsd || linux
+// +build darwin dragonfly freebsd linux
+
+package service
+
+import "golang.org/x/sys/unix"
+
+// statDiskUsage returns the usage of the file system containing the given
+// path.
+func statDiskUsage(path string) (DiskUsage, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return DiskUsage{}, err
+	}
+	return DiskUsage{
+		Path:        path,
+		Free:        uint64(st.Bavail) * uint64(st.Bsize),
+		Total:       uint64(st.Blocks) * uint64(st.Bsize),
+		FreeInodes:  uint64(st.Ffree),
+		TotalInodes: uint64(st.Files),
+	}, nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// A DualStackPolicy determines how ListenDualStack handles failing to
+// listen on one of the IP address families.
+type DualStackPolicy int
+
+const (
+	// RequireBoth fails unless both IPv4 and IPv6 can be listened on.
+	RequireBoth DualStackPolicy = iota
+
+	// PreferAny succeeds if either IPv4 or IPv6 can be listened on.
+	PreferAny
+)
+
+// A DualStackListener is a net.Listener that accepts connections from
+// separate IPv4 and IPv6 sockets.
+type DualStackListener struct {
+	ls []net.Listener
+
+	connC     chan acceptResult
+	closeOnce sync.Once
+	doneC     chan struct{}
+}
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// ListenDualStack listens for TCP connections on the given address, which
+// must not contain a host, using a separate socket for each of IPv4 and
+// IPv6 rather than relying on the system's handling of IPv4-mapped IPv6
+// addresses. If the port is 0 both sockets use the same port. The policy
+// determines whether failing to listen on one of the families is an
+// error. The listener is closed when the service shuts down.
+func (s *Service) ListenDualStack(address string, policy DualStackPolicy, opts ...ListenOption) (*DualStackListener, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if host != "" {
+		return nil, errors.New("dual-stack listen address " + address + " must not contain a host")
+	}
+	var ls []net.Listener
+	var errs []error
+	for _, network := range []string{"tcp4", "tcp6"} {
+		var l net.Listener
+		err := s.listen(opts, func() (err error) {
+			l, err = net.Listen(network, net.JoinHostPort("", port))
+			return err
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ls = append(ls, l)
+		if port == "0" {
+			_, port, _ = net.SplitHostPort(l.Addr().String())
+		}
+	}
+	if len(ls) == 0 || (policy == RequireBoth && len(errs) > 0) {
+		for _, l := range ls {
+			l.Close()
+		}
+		return nil, errors.Join(errs...)
+	}
+
+	dl := &DualStackListener{
+		ls:    ls,
+		connC: make(chan acceptResult),
+		doneC: make(chan struct{}),
+	}
+	for _, l := range ls {
+		s.addListenAddr(l.Addr())
+		go dl.accept(l)
+	}
+	s.OnShutdown(func() { dl.Close() })
+	return dl, nil
+}
+
+// accept accepts connections on one of the sockets until the listener is
+// closed. Temporary errors are retried with a backoff without being
+// returned by Accept; other errors are returned by Accept, and are also
+// followed by a backoff so that a persistent error does not spin.
+func (dl *DualStackListener) accept(l net.Listener) {
+	var backoff acceptBackoff
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if isTemporaryAcceptError(err) {
+				if !backoff.wait(dl.doneC) {
+					return
+				}
+				continue
+			}
+		}
+		select {
+		case dl.connC <- acceptResult{conn, err}:
+		case <-dl.doneC:
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+		if err == nil {
+			backoff.reset()
+		} else if !backoff.wait(dl.doneC) {
+			return
+		}
+	}
+}
+
+// Accept implements net.Listener, returning the next connection accepted
+// on either family.
+func (dl *DualStackListener) Accept() (net.Conn, error) {
+	select {
+	case r := <-dl.connC:
+		return r.conn, r.err
+	case <-dl.doneC:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close implements net.Listener, closing the sockets for both families.
+func (dl *DualStackListener) Close() error {
+	var errs []error
+	dl.closeOnce.Do(func() {
+		close(dl.doneC)
+		for _, l := range dl.ls {
+			if err := l.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// Addr implements net.Listener, returning the address of the first family
+// being listened on.
+func (dl *DualStackListener) Addr() net.Addr {
+	return dl.ls[0].Addr()
+}
+
+// Addrs returns the addresses of all the families being listened on.
+func (dl *DualStackListener) Addrs() []net.Addr {
+	addrs := make([]net.Addr, len(dl.ls))
+	for i, l := range dl.ls {
+		addrs[i] = l.Addr()
+	}
+	return addrs
+}
+
+// Families returns the IP address families being listened on, "tcp4"
+// and/or "tcp6".
+func (dl *DualStackListener) Families() []string {
+	var families []string
+	for _, l := range dl.ls {
+		if addr, ok := l.Addr().(*net.TCPAddr); ok && addr.IP.To4() == nil {
+			families = append(families, "tcp6")
+		} else {
+			families = append(families, "tcp4")
+		}
+	}
+	return families
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestListenDualStack(t *testing.T) {
+	_, svc := NewService(context.Background())
+	l, err := svc.ListenDualStack(":0", PreferAny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	families := l.Families()
+	if len(families) == 0 || families[0] != "tcp4" {
+		t.Fatal("unexpected families:", families)
+	}
+
+	// Both families share the same port.
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	hosts := map[string]string{"tcp4": "127.0.0.1", "tcp6": "::1"}
+	for _, family := range families {
+		c, err := net.Dial(family, net.JoinHostPort(hosts[family], port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	svc.Wait()
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestListenDualStackHost(t *testing.T) {
+	_, svc := NewService(context.Background())
+	if _, err := svc.ListenDualStack("localhost:0", RequireBoth); err == nil {
+		t.Error("expected error")
+	}
+}
+
+// flakyListener is a listener whose first Accept fails with a temporary
+// error.
+type flakyListener struct {
+	net.Listener
+	failed bool
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if !l.failed {
+		l.failed = true
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Err: timeoutError{}}
+	}
+	return l.Listener.Accept()
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestDualStackListenerTemporaryError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fl := &flakyListener{Listener: l}
+	dl := &DualStackListener{
+		ls:    []net.Listener{fl},
+		connC: make(chan acceptResult),
+		doneC: make(chan struct{}),
+	}
+	defer dl.Close()
+	go dl.accept(fl)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	connC := make(chan error, 1)
+	go func() {
+		conn, err := dl.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		connC <- err
+	}()
+	select {
+	case err := <-connC:
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not accepted after a temporary error")
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// An EveryOption configures GoEvery.
+type EveryOption func(*everyOptions)
+
+type everyOptions struct {
+	jitter    time.Duration
+	immediate bool
+	onError   func(error)
+}
+
+// WithJitter adds a random delay of up to the given duration to each
+// interval, so that many instances of a service do not all run their
+// periodic tasks at the same time.
+func WithJitter(d time.Duration) EveryOption {
+	return func(o *everyOptions) {
+		o.jitter = d
+	}
+}
+
+// WithImmediateRun runs the task as soon as it is started, rather than
+// after the first interval.
+func WithImmediateRun() EveryOption {
+	return func(o *everyOptions) {
+		o.immediate = true
+	}
+}
+
+// WithRunErrorHandler sets a function called with the errors returned by
+// the task. When this is set errors running the task do not stop the
+// service.
+func WithRunErrorHandler(f func(error)) EveryOption {
+	return func(o *everyOptions) {
+		o.onError = f
+	}
+}
+
+// GoEvery runs the given task in a goroutine of the service every given
+// interval, measured from the end of one run to the start of the next,
+// until the service starts shutting down. A run in progress when the
+// service shuts down is passed the service's context, and is waited for.
+// Unless WithRunErrorHandler is used an error from the task causes the
+// service to shut down. As with time.NewTicker, the interval must be
+// greater than zero.
+func (s *Service) GoEvery(interval time.Duration, task func(ctx context.Context) error, opts ...EveryOption) {
+	if interval <= 0 {
+		misuse("GoEvery", "called with a non-positive interval")
+	}
+	if s.opts.strict && task == nil {
+		misuse("GoEvery", "called with a nil function")
+	}
+	var o everyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	s.Go(func() error {
+		run := func() error {
+			err := task(s.ctx)
+			if err != nil && o.onError != nil {
+				o.onError(err)
+				return nil
+			}
+			return err
+		}
+		if o.immediate {
+			if err := run(); err != nil {
+				return err
+			}
+		}
+		t := time.NewTimer(0)
+		defer t.Stop()
+		for {
+			d := interval + jitter(o.jitter)
+			if !t.Stop() {
+				select {
+				case <-t.C:
+				default:
+				}
+			}
+			t.Reset(d)
+			select {
+			case <-s.doneC:
+				return nil
+			case <-t.C:
+			}
+			if s.IsShuttingDown() {
+				return nil
+			}
+			if err := run(); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// jitter returns a random duration of less than d, or zero if d is not
+// positive.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGoEvery(t *testing.T) {
+	_, svc := NewService(context.Background())
+	runs := make(chan time.Time, 10)
+	start := time.Now()
+	svc.GoEvery(time.Hour, func(ctx context.Context) error {
+		runs <- time.Now()
+		return nil
+	}, WithImmediateRun())
+	if run := <-runs; run.Sub(start) > time.Minute {
+		t.Error("task not run immediately")
+	}
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestGoEveryNonPositiveInterval(t *testing.T) {
+	_, svc := NewService(context.Background())
+	for _, interval := range []time.Duration{0, -time.Second} {
+		expectMisuse(t, "GoEvery", func() {
+			svc.GoEvery(interval, func(ctx context.Context) error {
+				t.Error("task run with interval", interval)
+				return nil
+			})
+		})
+	}
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestGoEveryError(t *testing.T) {
+	_, svc := NewService(context.Background())
+	runs := 0
+	svc.GoEvery(time.Millisecond, func(ctx context.Context) error {
+		runs++
+		if runs == 3 {
+			return errors.New("test error")
+		}
+		return nil
+	}, WithJitter(time.Millisecond))
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestGoEveryErrorHandler(t *testing.T) {
+	_, svc := NewService(context.Background())
+	var errs []error
+	svc.GoEvery(time.Millisecond, func(ctx context.Context) error {
+		return errors.New("test error")
+	}, WithRunErrorHandler(func(err error) {
+		if errs = append(errs, err); len(errs) == 3 {
+			svc.Shutdown(nil)
+		}
+	}))
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if len(errs) != 3 {
+		t.Error("unexpected errors:", errs)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// FDUsage reports the file descriptors used by the process.
+type FDUsage struct {
+	// Open is the number of open file descriptors.
+	Open uint64
+
+	// Limit is the soft limit on the number of open file descriptors,
+	// RLIMIT_NOFILE.
+	Limit uint64
+
+	// Kinds counts the open file descriptors by the kind of file they
+	// refer to, such as "file", "socket", "pipe" or "anon_inode". It is
+	// only set on usage passed to an FDMonitor's OnHigh function.
+	Kinds map[string]int
+}
+
+// Utilization returns the fraction of the limit in use.
+func (u FDUsage) Utilization() float64 {
+	if u.Limit == 0 {
+		return 0
+	}
+	return float64(u.Open) / float64(u.Limit)
+}
+
+// An FDMonitor tracks the number of file descriptors the process has open
+// against its limit, so that a service can report that it is close to
+// running out before accepting connections and opening files starts to
+// fail. An FDMonitor is normally run as one of the goroutines of a
+// service:
+//
+//	svc.Go(func() error { return m.Run(ctx) })
+type FDMonitor struct {
+	// Interval is the time between checks. If this is zero or negative the
+	// file descriptors are checked every 10 seconds.
+	Interval time.Duration
+
+	// Threshold is the fraction of the limit above which usage is high.
+	// If this is zero or negative 0.8 is used.
+	Threshold float64
+
+	// OnHigh, if set, is called when usage rises above the threshold,
+	// with a summary of the kinds of the open file descriptors. OnNormal,
+	// if set, is called when usage falls back below the threshold.
+	OnHigh   func(FDUsage)
+	OnNormal func(FDUsage)
+
+	// OnError, if set, is called for each check that fails.
+	OnError func(error)
+}
+
+// Run checks the file descriptor usage until the given context is done.
+// Run always returns nil, with errors checking the usage reported to
+// OnError.
+func (m *FDMonitor) Run(ctx context.Context) error {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	threshold := m.Threshold
+	if threshold <= 0 {
+		threshold = 0.8
+	}
+	high := false
+	check := func() {
+		u, err := fdUsage(false)
+		if err == nil && !high && u.Utilization() > threshold {
+			u, err = fdUsage(true)
+		}
+		if err != nil {
+			if m.OnError != nil {
+				m.OnError(err)
+			}
+			return
+		}
+		isHigh := u.Utilization() > threshold
+		switch {
+		case isHigh && !high && m.OnHigh != nil:
+			m.OnHigh(u)
+		case !isHigh && high && m.OnNormal != nil:
+			m.OnNormal(u)
+		}
+		high = isHigh
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	check()
+	for {
+		select {
+		case <-t.C:
+			check()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// fdUsage is a variable so that tests can simulate running out of file
+// descriptors.
+var fdUsage = readFDUsage
@@ -0,0 +1,42 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// readFDUsage returns the process's file descriptor usage, counting the
+// kinds of the open file descriptors if requested.
+func readFDUsage(kinds bool) (FDUsage, error) {
+	var rlim unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rlim); err != nil {
+		return FDUsage{}, err
+	}
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return FDUsage{}, err
+	}
+	u := FDUsage{Open: uint64(len(entries)), Limit: rlim.Cur}
+	if !kinds {
+		return u, nil
+	}
+	u.Kinds = make(map[string]int)
+	for _, e := range entries {
+		target, err := os.Readlink(filepath.Join("/proc/self/fd", e.Name()))
+		if err != nil {
+			// The file descriptor was closed while reading the directory.
+			continue
+		}
+		kind := "file"
+		if i := strings.Index(target, ":"); i > 0 && !strings.HasPrefix(target, "/") {
+			kind = target[:i]
+		}
+		u.Kinds[kind]++
+	}
+	return u, nil
+}
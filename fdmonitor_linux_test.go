@@ -0,0 +1,23 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"os"
+	"testing"
+)
+
+func TestReadFDUsage(t *testing.T) {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	u, err := readFDUsage(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Open == 0 || u.Limit == 0 || u.Kinds["file"] == 0 {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+}
@@ -0,0 +1,14 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build !linux
+// +build !linux
+
+package service
+
+import "errors"
+
+// readFDUsage returns the process's file descriptor usage. File
+// descriptor usage is not supported on this platform.
+func readFDUsage(kinds bool) (FDUsage, error) {
+	return FDUsage{}, errors.New("file descriptor usage is not supported on this platform")
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFDMonitor(t *testing.T) {
+	var mu sync.Mutex
+	open := uint64(10)
+	defer func(f func(bool) (FDUsage, error)) { fdUsage = f }(fdUsage)
+	fdUsage = func(kinds bool) (FDUsage, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		u := FDUsage{Open: open, Limit: 100}
+		if kinds {
+			u.Kinds = map[string]int{"socket": int(open)}
+		}
+		return u, nil
+	}
+	setOpen := func(n uint64) {
+		mu.Lock()
+		open = n
+		mu.Unlock()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan FDUsage, 10)
+	m := &FDMonitor{
+		Interval: time.Millisecond,
+		OnHigh:   func(u FDUsage) { events <- u },
+		OnNormal: func(u FDUsage) { events <- u },
+	}
+	go m.Run(ctx)
+	setOpen(90)
+	if u := <-events; u.Open != 90 || u.Kinds["socket"] != 90 {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+	setOpen(20)
+	if u := <-events; u.Open != 20 || u.Kinds != nil {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+}
+
+func TestFDMonitorNegativeSettings(t *testing.T) {
+	defer func(f func(bool) (FDUsage, error)) { fdUsage = f }(fdUsage)
+	fdUsage = func(bool) (FDUsage, error) {
+		return FDUsage{Open: 10, Limit: 100}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m := &FDMonitor{
+		Interval:  -time.Second,
+		Threshold: -1,
+		OnHigh:    func(u FDUsage) { t.Errorf("unexpected high usage: %+v", u) },
+	}
+	if err := m.Run(ctx); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
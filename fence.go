@@ -0,0 +1,83 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// A Fence holds back part of a service's shutdown until other components
+// have released it, such as not closing a write-ahead log until a
+// snapshotter has confirmed that it has switched to a new file. Fences
+// are created with Service.Fence.
+type Fence struct {
+	s *Service
+
+	mu       sync.Mutex
+	holds    int
+	released chan struct{}
+}
+
+// Fence returns a new fence for the service, initially released.
+func (s *Service) Fence() *Fence {
+	return &Fence{s: s}
+}
+
+// Hold holds the fence. Every call to Hold must be matched by a call to
+// Release.
+func (f *Fence) Hold() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.holds == 0 {
+		f.released = make(chan struct{})
+	}
+	f.holds++
+}
+
+// Release releases one hold of the fence.
+func (f *Fence) Release() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.holds == 0 {
+		misuse("Fence.Release", "called more times than Hold")
+	}
+	f.holds--
+	if f.holds == 0 {
+		close(f.released)
+	}
+}
+
+// Wait waits until the fence has no holds or the given context is done,
+// returning the context's error in the latter case.
+func (f *Fence) Wait(ctx context.Context) error {
+	f.mu.Lock()
+	if f.holds == 0 {
+		f.mu.Unlock()
+		return nil
+	}
+	released := f.released
+	f.mu.Unlock()
+	select {
+	case <-released:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// OnShutdown registers a function to be called when the service shuts
+// down in the same way as Service.OnShutdown, but only once the fence has
+// been released or the shutdown budget has run out. While it waits the
+// shutdown functions that would run after it are held back too, so the
+// fence should be released by goroutines of the service, or by shutdown
+// functions registered after this one, rather than by those registered
+// before it.
+func (f *Fence) OnShutdown(fn func()) {
+	f.s.addHook("Fence.OnShutdown", nil, "", func() {
+		ctx, cancel := f.s.budget.hookContext(context.Background())
+		defer cancel()
+		f.Wait(ctx)
+		fn()
+	})
+}
@@ -0,0 +1,53 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFence(t *testing.T) {
+	ctx, svc := NewService(context.Background())
+	f := svc.Fence()
+	var ops []string
+	f.OnShutdown(func() { ops = append(ops, "close wal") })
+	f.Hold()
+	svc.Go(func() error {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		ops = append(ops, "switch files")
+		f.Release()
+		return nil
+	})
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if len(ops) != 2 || ops[0] != "switch files" || ops[1] != "close wal" {
+		t.Error("unexpected operations:", ops)
+	}
+}
+
+func TestFenceShutdownTimeout(t *testing.T) {
+	_, svc := New(context.Background(), WithShutdownTimeout(10*time.Millisecond))
+	f := svc.Fence()
+	f.Hold()
+	closed := make(chan struct{})
+	f.OnShutdown(func() { close(closed) })
+	svc.Shutdown(nil)
+	svc.Wait()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Error("shutdown function not called once the budget ran out")
+	}
+}
+
+func TestFenceReleaseMisuse(t *testing.T) {
+	_, svc := NewService(context.Background())
+	expectMisuse(t, "Fence.Release", func() { svc.Fence().Release() })
+	svc.Shutdown(nil)
+	svc.Wait()
+}
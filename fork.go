@@ -0,0 +1,20 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import "os"
+
+// getpid returns the process ID. It is a variable so that tests can
+// simulate a fork.
+var getpid = os.Getpid
+
+// checkFork panics with a *MisuseError if the service is being used by a
+// process forked from the one that created it. A forked child inherits
+// the service's state, but not the goroutines or signal handlers behind
+// it, so using the service there would silently misbehave. The child
+// should create a new service of its own.
+func (s *Service) checkFork(method string) {
+	if getpid() != s.pid {
+		misuse(method, "called in a process forked after the service was created; create a new service in the child process")
+	}
+}
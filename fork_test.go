@@ -0,0 +1,28 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestForkedService(t *testing.T) {
+	_, svc := New(context.Background(), WithStrict())
+	defer func(f func() int) { getpid = f }(getpid)
+	pid := getpid()
+	getpid = func() int { return pid + 1 }
+
+	expectMisuse(t, "Go", func() { svc.Go(func() error { return nil }) })
+	expectMisuse(t, "OnShutdown", func() { svc.OnShutdown(func() {}) })
+	expectMisuse(t, "Wait", func() { svc.Wait() })
+	getpid = func() int { return pid }
+	svc.Shutdown(nil)
+	svc.Wait()
+
+	_, child := NewService(context.Background())
+	child.Shutdown(nil)
+	if err := child.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
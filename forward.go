@@ -0,0 +1,192 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// A Forwarder is a TCP proxy that forwards every connection it accepts to
+// a target address. When the context given to Serve is done the
+// forwarder stops accepting connections and waits for the connections it
+// is forwarding to finish before returning. A Forwarder is normally run
+// as one of the goroutines of a service:
+//
+//	svc.Go(func() error { return f.Serve(ctx, l) })
+type Forwarder struct {
+	// Network is the network of the target address. If this is empty
+	// "tcp" is used.
+	Network string
+
+	// Address is the target address connections are forwarded to.
+	Address string
+
+	// Dial, if set, is used to connect to the target address. If this is
+	// nil a zero net.Dialer is used.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// DrainTimeout is the maximum time Serve waits for forwarded
+	// connections to finish once its context is done, after which any
+	// remaining connections are closed. If this is zero remaining
+	// connections are closed immediately.
+	DrainTimeout time.Duration
+
+	// OnError, if set, is called with errors forwarding individual
+	// connections, such as failing to connect to the target.
+	OnError func(error)
+
+	mu    sync.Mutex
+	conns map[net.Conn]net.Conn
+	wg    sync.WaitGroup
+}
+
+// Serve accepts connections on the given listener and forwards them until
+// the context is done, then drains the forwarded connections. The
+// listener is closed when Serve returns. Serve returns nil if it stopped
+// because the context is done, otherwise the error that stopped the
+// listener.
+func (f *Forwarder) Serve(ctx context.Context, l net.Listener) error {
+	stopC := make(chan struct{})
+	defer close(stopC)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stopC:
+		}
+		l.Close()
+	}()
+
+	var err error
+	for {
+		var conn net.Conn
+		conn, err = l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				err = nil
+				break
+			}
+			if isTemporaryAcceptError(err) {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			break
+		}
+		f.track(conn, nil)
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			f.forward(ctx, conn)
+		}()
+	}
+	f.drain()
+	return err
+}
+
+// Conns returns the number of connections currently being forwarded.
+func (f *Forwarder) Conns() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.conns)
+}
+
+func (f *Forwarder) forward(ctx context.Context, conn net.Conn) {
+	defer func() {
+		f.untrack(conn)
+		conn.Close()
+	}()
+	network := f.Network
+	if network == "" {
+		network = "tcp"
+	}
+	dial := f.Dial
+	if dial == nil {
+		dial = new(net.Dialer).DialContext
+	}
+	target, err := dial(ctx, network, f.Address)
+	if err != nil {
+		if f.OnError != nil {
+			f.OnError(err)
+		}
+		return
+	}
+	defer target.Close()
+	if !f.track(conn, target) {
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		splice(target, conn)
+	}()
+	splice(conn, target)
+	wg.Done()
+	wg.Wait()
+}
+
+// drain waits for the forwarded connections to finish, closing any that
+// remain after the drain timeout.
+func (f *Forwarder) drain() {
+	doneC := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(doneC)
+	}()
+	t := time.NewTimer(f.DrainTimeout)
+	defer t.Stop()
+	select {
+	case <-doneC:
+		return
+	case <-t.C:
+	}
+	f.mu.Lock()
+	for c, target := range f.conns {
+		c.Close()
+		if target != nil {
+			target.Close()
+		}
+	}
+	f.conns = nil
+	f.mu.Unlock()
+	<-doneC
+}
+
+// track records a forwarded connection and the target connection it is
+// forwarded to, if known. It reports false if the connection has since
+// been closed by the drain.
+func (f *Forwarder) track(c, target net.Conn) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if target != nil {
+		if _, ok := f.conns[c]; !ok {
+			return false
+		}
+	}
+	if f.conns == nil {
+		f.conns = make(map[net.Conn]net.Conn)
+	}
+	f.conns[c] = target
+	return true
+}
+
+func (f *Forwarder) untrack(c net.Conn) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.conns, c)
+}
+
+// splice copies from src to dst, then closes the write side of dst so the
+// peer sees the end of the stream.
+func splice(dst, src net.Conn) {
+	io.Copy(dst, src)
+	if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	} else {
+		dst.Close()
+	}
+}
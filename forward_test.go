@@ -0,0 +1,78 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestForwarder(t *testing.T) {
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	go func() {
+		for {
+			c, err := backend.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				io.Copy(c, c)
+			}()
+		}
+	}()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, svc := NewService(context.Background())
+	f := &Forwarder{
+		Address:      backend.Addr().String(),
+		DrainTimeout: 10 * time.Millisecond,
+	}
+	svc.Go(func() error { return f.Serve(ctx, l) })
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(c).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "hello\n" {
+		t.Errorf("unexpected response %q", line)
+	}
+	if n := f.Conns(); n != 1 {
+		t.Error("unexpected connection count:", n)
+	}
+
+	// The connection is idle, so shutting down closes it after the
+	// drain timeout.
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+	if n := f.Conns(); n != 0 {
+		t.Error("unexpected connection count:", n)
+	}
+	if _, err := net.Dial("tcp", l.Addr().String()); err == nil {
+		t.Error("listener not closed")
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// A GoroutineAlarm is raised by a GoroutineMonitor.
+type GoroutineAlarm struct {
+	// Count is the number of goroutines when the alarm was raised.
+	Count int
+
+	// Growing is true if the alarm was raised because the number of
+	// goroutines grew for the whole window, rather than because it
+	// exceeded the budget.
+	Growing bool
+
+	// Dump is the stack traces of all the goroutines, if the monitor was
+	// configured to include them.
+	Dump []byte
+}
+
+// A GoroutineMonitor tracks the number of goroutines in the process to
+// catch goroutine leaks in long running services. An alarm is raised
+// when the number of goroutines exceeds a budget, or grows at every
+// check over a window of checks. A GoroutineMonitor is normally run as
+// one of the goroutines of a service:
+//
+//	svc.Go(func() error { return m.Run(ctx) })
+type GoroutineMonitor struct {
+	// Interval is the time between checks. If this is zero or negative
+	// the goroutines are counted every minute.
+	Interval time.Duration
+
+	// Budget is the number of goroutines above which an alarm is
+	// raised. If this is zero the number is not limited.
+	Budget int
+
+	// Window is the number of consecutive checks over which the number
+	// of goroutines must grow for an alarm to be raised. If this is zero
+	// growth is not tracked.
+	Window int
+
+	// Dump includes the stack traces of all goroutines in each alarm.
+	Dump bool
+
+	// OnAlarm is called with each alarm. An alarm is only raised again
+	// once the number of goroutines has fallen back within the budget,
+	// or has stopped growing.
+	OnAlarm func(GoroutineAlarm)
+}
+
+// Run counts the goroutines until the given context is done. Run always
+// returns nil.
+func (m *GoroutineMonitor) Run(ctx context.Context) error {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	var overBudget, growing bool
+	last, grown := 0, 0
+	check := func() {
+		n := numGoroutine()
+		if last > 0 && n > last {
+			grown++
+		} else {
+			grown = 0
+		}
+		last = n
+		isOver := m.Budget > 0 && n > m.Budget
+		isGrowing := m.Window > 0 && grown >= m.Window
+		if isOver && !overBudget || isGrowing && !growing {
+			m.alarm(GoroutineAlarm{Count: n, Growing: !isOver || overBudget})
+		}
+		overBudget, growing = isOver, isGrowing
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	check()
+	for {
+		select {
+		case <-t.C:
+			check()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// alarm raises an alarm, adding a dump of the goroutines if required.
+func (m *GoroutineMonitor) alarm(a GoroutineAlarm) {
+	if m.Dump {
+		var buf bytes.Buffer
+		pprof.Lookup("goroutine").WriteTo(&buf, 1)
+		a.Dump = buf.Bytes()
+	}
+	if m.OnAlarm != nil {
+		m.OnAlarm(a)
+	}
+}
+
+// numGoroutine is a variable so that tests can simulate goroutine leaks.
+var numGoroutine = runtime.NumGoroutine
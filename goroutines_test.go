@@ -0,0 +1,58 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGoroutineMonitor(t *testing.T) {
+	defer func(f func() int) { numGoroutine = f }(numGoroutine)
+	ctx, cancel := context.WithCancel(context.Background())
+	counts := []int{10, 20, 5, 6, 7, 8, 9, 4}
+	numGoroutine = func() int {
+		n := counts[0]
+		if len(counts) > 1 {
+			counts = counts[1:]
+		} else {
+			cancel()
+		}
+		return n
+	}
+
+	var alarms []GoroutineAlarm
+	m := &GoroutineMonitor{
+		Interval: 1,
+		Budget:   15,
+		Window:   3,
+		Dump:     true,
+		OnAlarm:  func(a GoroutineAlarm) { alarms = append(alarms, a) },
+	}
+	if err := m.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(alarms) != 2 {
+		t.Fatalf("unexpected alarms: %+v", alarms)
+	}
+	if alarms[0].Count != 20 || alarms[0].Growing {
+		t.Errorf("unexpected alarm: %+v", alarms[0])
+	}
+	if alarms[1].Count != 8 || !alarms[1].Growing {
+		t.Errorf("unexpected alarm: %+v", alarms[1])
+	}
+	if !bytes.Contains(alarms[0].Dump, []byte("goroutine profile")) {
+		t.Error("unexpected dump:", string(alarms[0].Dump))
+	}
+}
+
+func TestGoroutineMonitorNegativeInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m := &GoroutineMonitor{Interval: -time.Second}
+	if err := m.Run(ctx); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"net"
+	"time"
+)
+
+// A GRPCServer is a server that can be run with ServeGRPCServer. It is
+// satisfied by *grpc.Server, without this package depending on gRPC.
+type GRPCServer interface {
+	Serve(net.Listener) error
+	GracefulStop()
+	Stop()
+}
+
+// ServeGRPCServer runs the given gRPC server on the given listener in a
+// goroutine of the service. Errors serving, such as the listener failing,
+// are returned by Wait.
+//
+// When the service shuts down a shutdown function gracefully stops the
+// server, allowing the RPCs in progress up to the given timeout, and no
+// longer than the service's remaining shutdown budget, less any part
+// reserved for critical shutdown functions, to finish before the server
+// is stopped. If the timeout is zero only the shutdown budget applies.
+func (s *Service) ServeGRPCServer(srv GRPCServer, l net.Listener, timeout time.Duration) {
+	s.OnShutdown(func() {
+		d := s.budget.Remaining()
+		if deadline, ok := s.budget.hookDeadline(); ok {
+			d = max(time.Until(deadline), 0)
+		}
+		if timeout > 0 && timeout < d {
+			d = timeout
+		}
+		done := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(done)
+		}()
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-done:
+		case <-t.C:
+			srv.Stop()
+			<-done
+		}
+	})
+	s.Go(func() error {
+		return srv.Serve(l)
+	})
+}
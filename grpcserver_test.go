@@ -0,0 +1,87 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeGRPCServer behaves like a grpc.Server with an RPC in progress that
+// never finishes.
+type fakeGRPCServer struct {
+	stopped  chan struct{}
+	graceful bool
+}
+
+func (f *fakeGRPCServer) Serve(l net.Listener) error {
+	if l == nil {
+		return errors.New("test error")
+	}
+	<-f.stopped
+	return nil
+}
+
+func (f *fakeGRPCServer) GracefulStop() {
+	f.graceful = true
+	<-f.stopped
+}
+
+func (f *fakeGRPCServer) Stop() {
+	close(f.stopped)
+}
+
+func TestServeGRPCServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	_, svc := NewService(context.Background())
+	srv := &fakeGRPCServer{stopped: make(chan struct{})}
+	svc.ServeGRPCServer(srv, l, 10*time.Millisecond)
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !srv.graceful {
+		t.Error("server not gracefully stopped")
+	}
+}
+
+func TestServeGRPCServerCriticalReserve(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	_, svc := New(context.Background(), WithShutdownTimeout(time.Second))
+	srv := &fakeGRPCServer{stopped: make(chan struct{})}
+	svc.OnShutdownCritical(990*time.Millisecond, func(ctx context.Context) error {
+		select {
+		case <-srv.stopped:
+			return nil
+		default:
+			return errors.New("server still running")
+		}
+	})
+	svc.ServeGRPCServer(srv, l, 0)
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if errs := svc.ShutdownErrors(); len(errs) != 0 {
+		t.Error("unexpected shutdown errors:", errs)
+	}
+}
+
+func TestServeGRPCServerError(t *testing.T) {
+	_, svc := NewService(context.Background())
+	svc.ServeGRPCServer(&fakeGRPCServer{stopped: make(chan struct{})}, nil, time.Millisecond)
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+}
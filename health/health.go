@@ -0,0 +1,168 @@
+// Copyright 2021 Canonical Ltd.
+
+// Package health provides a registry of named health checks whose results
+// are aggregated into the health of a service.
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/canonical/go-service"
+)
+
+// A Check checks the health of one component, returning an error if it
+// is unhealthy.
+type Check func(ctx context.Context) error
+
+// A Result is the result of running a check.
+type Result struct {
+	// Healthy reports whether the check passed.
+	Healthy bool `json:"healthy"`
+
+	// Error is the error returned by a failed check.
+	Error string `json:"error,omitempty"`
+
+	// Time is when the check was run.
+	Time time.Time `json:"time"`
+
+	// Duration is how long the check took.
+	Duration time.Duration `json:"duration"`
+}
+
+// A Report is the aggregated result of running all the checks in a
+// registry.
+type Report struct {
+	// Healthy reports whether all the checks passed.
+	Healthy bool `json:"healthy"`
+
+	// Checks holds the result of each check, by name.
+	Checks map[string]Result `json:"checks"`
+}
+
+// Failing returns the names of the failed checks, in order.
+func (r Report) Failing() []string {
+	var names []string
+	for name, res := range r.Checks {
+		if !res.Healthy {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// A Registry holds the health checks of a service. The zero value is an
+// empty registry ready to use.
+type Registry struct {
+	// Timeout is the time allowed for each check. If this is zero the
+	// checks are only bounded by the context passed to Run.
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	checks  map[string]Check
+	results map[string]Result
+}
+
+// Register adds a check to the registry, replacing any check with the
+// same name. Until it is first run the check is reported as healthy.
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.checks == nil {
+		r.checks = make(map[string]Check)
+	}
+	r.checks[name] = check
+}
+
+// Run runs all the checks concurrently, waiting for them to finish, and
+// returns the resulting report.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.Lock()
+	checks := make(map[string]Check, len(r.checks))
+	for name, check := range r.checks {
+		checks[name] = check
+	}
+	r.mu.Unlock()
+
+	var mu sync.Mutex
+	results := make(map[string]Result, len(checks))
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			res := r.run(ctx, check)
+			mu.Lock()
+			results[name] = res
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	if r.results == nil {
+		r.results = make(map[string]Result)
+	}
+	for name, res := range results {
+		if _, ok := r.checks[name]; ok {
+			r.results[name] = res
+		}
+	}
+	r.mu.Unlock()
+	return r.Report()
+}
+
+// run runs a single check.
+func (r *Registry) run(ctx context.Context, check Check) Result {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	err := check(ctx)
+	res := Result{Healthy: err == nil, Time: start, Duration: time.Since(start)}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// Report returns the results of the most recent run of each check.
+func (r *Registry) Report() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := Report{Healthy: true, Checks: make(map[string]Result, len(r.checks))}
+	for name := range r.checks {
+		res, ok := r.results[name]
+		if !ok {
+			res = Result{Healthy: true}
+		}
+		rep.Checks[name] = res
+		if !res.Healthy {
+			rep.Healthy = false
+		}
+	}
+	return rep
+}
+
+// Healthy reports whether the most recent run of every check passed.
+func (r *Registry) Healthy() bool {
+	return r.Report().Healthy
+}
+
+// RunEvery runs the checks in a goroutine of the given service every
+// given interval, starting immediately, until the service starts shutting
+// down. The interval must be greater than zero.
+func (r *Registry) RunEvery(svc *service.Service, interval time.Duration) {
+	if interval <= 0 {
+		panic("health: non-positive interval for RunEvery")
+	}
+	svc.GoEvery(interval, func(ctx context.Context) error {
+		r.Run(ctx)
+		return nil
+	}, service.WithImmediateRun())
+}
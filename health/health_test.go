@@ -0,0 +1,74 @@
+// Copyright 2021 Canonical Ltd.
+
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-service"
+)
+
+func TestRegistry(t *testing.T) {
+	var r Registry
+	r.Timeout = 10 * time.Millisecond
+	r.Register("db", func(ctx context.Context) error { return nil })
+	r.Register("cache", func(ctx context.Context) error { return errors.New("test error") })
+	r.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !r.Healthy() {
+		t.Error("unexpected unhealthy registry before checks run")
+	}
+	rep := r.Run(context.Background())
+	if rep.Healthy || r.Healthy() {
+		t.Error("unexpected healthy report")
+	}
+	if failing := rep.Failing(); len(failing) != 2 || failing[0] != "cache" || failing[1] != "slow" {
+		t.Error("unexpected failing checks:", failing)
+	}
+	if res := rep.Checks["cache"]; res.Error != "test error" || res.Time.IsZero() {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
+
+func TestRunEvery(t *testing.T) {
+	var r Registry
+	ran := make(chan struct{}, 10)
+	r.Register("db", func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	})
+	_, svc := service.NewService(context.Background())
+	r.RunEvery(svc, time.Hour)
+	<-ran
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if rep := r.Report(); !rep.Healthy || rep.Checks["db"].Time.IsZero() {
+		t.Errorf("unexpected report: %+v", rep)
+	}
+}
+
+func TestRunEveryNonPositiveInterval(t *testing.T) {
+	var r Registry
+	_, svc := service.NewService(context.Background())
+	for _, interval := range []time.Duration{0, -time.Second} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic for interval", interval)
+				}
+			}()
+			r.RunEvery(svc, interval)
+		}()
+	}
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 Canonical Ltd.
+
+package health
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/canonical/go-service"
+)
+
+// Handler returns an HTTP handler serving liveness and readiness probes
+// for the given service. The /livez endpoint always responds with 200 OK
+// while the process is running. The /readyz endpoint responds with 200 OK
+// once the service is ready, as reported by its Ready method, if the most
+// recent run of every check in the registry passed, and with 503 Service
+// Unavailable otherwise, or as soon as the service starts shutting down,
+// so that load balancers stop routing traffic to the service before its
+// connections are drained. The body of each /readyz response is the JSON
+// encoded Report.
+func Handler(svc *service.Service, r *Registry) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/livez", func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "ok\n")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, req *http.Request) {
+		rep := r.Report()
+		select {
+		case <-svc.Ready():
+		default:
+			rep.Healthy = false
+		}
+		if svc.IsShuttingDown() {
+			rep.Healthy = false
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !rep.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(rep)
+	})
+	return mux
+}
+
+// ServeEndpoints serves the probes returned by Handler on the given
+// address in a goroutine of the service. The server is shut down by one
+// of the service's shutdown functions, so until then it keeps answering
+// probes, with the service reported as not ready.
+func ServeEndpoints(svc *service.Service, r *Registry, addr string) error {
+	l, err := svc.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	svc.ServeHTTPServer(&http.Server{Handler: Handler(svc, r)}, l, 0)
+	return nil
+}
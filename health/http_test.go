@@ -0,0 +1,83 @@
+// Copyright 2021 Canonical Ltd.
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/canonical/go-service"
+)
+
+func TestHandler(t *testing.T) {
+	_, svc := service.NewService(context.Background())
+	var r Registry
+	failing := false
+	r.Register("db", func(ctx context.Context) error {
+		if failing {
+			return errors.New("test error")
+		}
+		return nil
+	})
+	h := Handler(svc, &r)
+	probe := func(path string) (int, Report) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		var rep Report
+		if path == "/readyz" {
+			if err := json.Unmarshal(w.Body.Bytes(), &rep); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code, rep
+	}
+
+	if code, _ := probe("/readyz"); code != http.StatusServiceUnavailable {
+		t.Error("unexpected readiness before start:", code)
+	}
+	errC := make(chan error)
+	go func() { errC <- svc.Wait() }()
+	<-svc.Ready()
+	if code, rep := probe("/readyz"); code != http.StatusOK || !rep.Healthy {
+		t.Errorf("unexpected readiness: %d %+v", code, rep)
+	}
+	failing = true
+	r.Run(context.Background())
+	if code, rep := probe("/readyz"); code != http.StatusServiceUnavailable || rep.Checks["db"].Error != "test error" {
+		t.Errorf("unexpected readiness: %d %+v", code, rep)
+	}
+	failing = false
+	r.Run(context.Background())
+	svc.Shutdown(nil)
+	<-errC
+	if code, _ := probe("/readyz"); code != http.StatusServiceUnavailable {
+		t.Error("unexpected readiness after shutdown:", code)
+	}
+	if code, _ := probe("/livez"); code != http.StatusOK {
+		t.Error("unexpected liveness:", code)
+	}
+}
+
+func TestServeEndpoints(t *testing.T) {
+	_, svc := service.NewService(context.Background())
+	var r Registry
+	if err := ServeEndpoints(svc, &r, "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get("http://" + svc.ListenAddrs()[0].String() + "/livez")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Error("unexpected status:", resp.Status)
+	}
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// A hook is a function registered with OnShutdown. Hooks are kept small,
+// with the group given by its index in the service's hookGroups, as one
+// is stored for every call to OnShutdown.
+type hook struct {
+	seq      uint64
+	f        func()
+	group    uint32
+	critical bool
+}
+
+// OnShutdownGroup registers a function to be called when the service
+// shuts down in the same way as OnShutdown, as part of the named group.
+// Groups are normally named after the component registering the
+// functions.
+//
+// When there are functions in more than one group they are run taking
+// one function from each group in turn, where functions registered with
+// OnShutdown and OnShutdownScoped form a group of their own. Within each
+// group the most recently registered function is run first. This stops a
+// component that has registered a large number of functions from delaying
+// the shutdown functions of every other component until the shutdown
+// budget has run out.
+func (s *Service) OnShutdownGroup(group string, f func()) {
+	if s.opts.strict && s.isStopped() {
+		misuse("OnShutdownGroup", "called after Wait returned; the function would never be waited for")
+	}
+	s.addHook("OnShutdownGroup", nil, group, f)
+}
+
+// OnShutdownContext registers a function to be called when the service
+// shuts down in the same way as OnShutdown. The function is passed a
+// context that expires when the shutdown budget runs out or, if the
+// service was created with WithHookTimeout, once the function has run
+// for the hook timeout, whichever is sooner. Errors returned by the
+// function are reported by ShutdownErrors.
+func (s *Service) OnShutdownContext(f func(ctx context.Context) error) {
+	if s.opts.strict {
+		if f == nil {
+			misuse("OnShutdownContext", "called with a nil function")
+		}
+		if s.isStopped() {
+			misuse("OnShutdownContext", "called after Wait returned; the function would never be waited for")
+		}
+	}
+	s.addHook("OnShutdownContext", nil, "", func() {
+		ctx, cancel := s.budget.hookContext(context.WithoutCancel(s.ctx))
+		defer cancel()
+		if s.opts.hookTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.opts.hookTimeout)
+			defer cancel()
+		}
+		if err := f(ctx); err != nil {
+			s.addShutdownError(err)
+		}
+	})
+}
+
+// OnShutdownCritical registers a function to be called when the service
+// shuts down, such as syncing a write-ahead log or releasing a lease,
+// that must run even if other shutdown functions overrun. The given
+// amount of the shutdown budget set with WithShutdownTimeout is reserved
+// for the function: the contexts passed to functions registered with
+// OnShutdownContext expire early enough to leave the reserved time, and
+// if a function that is not critical is still running once only the
+// reserved time is left it is abandoned, the remaining functions that are
+// not critical are skipped, and the error is reported by ShutdownErrors.
+//
+// The critical functions are run after all the others, most recently
+// registered first. Each is passed a context that expires when the whole
+// shutdown budget runs out, and any error it returns is reported by
+// ShutdownErrors.
+//
+// A negative reserve is treated as zero. If the reserves add up to more
+// than the total budget no time is left for the functions that are not
+// critical: their contexts expire as soon as the service starts shutting
+// down, and they are abandoned at once.
+func (s *Service) OnShutdownCritical(reserve time.Duration, f func(ctx context.Context) error) {
+	if s.opts.strict {
+		if f == nil {
+			misuse("OnShutdownCritical", "called with a nil function")
+		}
+		if s.isStopped() {
+			misuse("OnShutdownCritical", "called after Wait returned; the function would never be waited for")
+		}
+	}
+	s.budget.reserve(reserve)
+	s.registerHook("OnShutdownCritical", nil, "", hook{critical: true, f: func() {
+		ctx, cancel := s.budget.Context(context.WithoutCancel(s.ctx))
+		defer cancel()
+		if err := f(ctx); err != nil {
+			s.addShutdownError(err)
+		}
+	}})
+}
+
+// OnShutdownScoped registers a function to be called when the service
+// shuts down in the same way as OnShutdown, unless the given context is
+// done first, in which case the function is discarded without being
+// called. This allows per-request or per-connection cleanup to be
+// registered without the function being held until the service stops.
+func (s *Service) OnShutdownScoped(ctx context.Context, f func()) {
+	if s.opts.strict && s.isStopped() {
+		misuse("OnShutdownScoped", "called after Wait returned; the function would never be waited for")
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	s.addHook("OnShutdownScoped", ctx, "", f)
+}
+
+// HookCount returns the number of shutdown functions waiting to be run.
+func (s *Service) HookCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.hooks) + len(s.scopedHooks)
+}
+
+// addHook registers a shutdown function in the given group, scoped to the
+// given context if it is not nil. If the service is already shutting down
+// the function is called immediately.
+func (s *Service) addHook(method string, ctx context.Context, group string, f func()) {
+	s.registerHook(method, ctx, group, hook{f: f})
+}
+
+// registerHook registers the shutdown function of the given hook in the
+// given group, in the same way as addHook.
+func (s *Service) registerHook(method string, ctx context.Context, group string, h hook) {
+	f := h.f
+	if s.opts.strict {
+		s.checkFork(method)
+		if f == nil {
+			misuse(method, "called with a nil function")
+		}
+	}
+	if s.opts.wrapHook != nil {
+		f = s.opts.wrapHook(f)
+	}
+	if s.opts.recoverPanics {
+		f = s.recoverHookPanic(f)
+	}
+	s.mu.Lock()
+	if s.hooksStarted {
+		s.mu.Unlock()
+		f()
+		return
+	}
+	if ctx == nil && s.opts.maxHooks > 0 && len(s.hooks) >= s.opts.maxHooks {
+		err := &MisuseError{
+			Method: method,
+			Reason: "called with " + strconv.Itoa(s.opts.maxHooks) + " shutdown functions already registered; use OnShutdownScoped for per-connection cleanup",
+		}
+		if s.opts.strict {
+			s.mu.Unlock()
+			panic(err)
+		}
+		s.shutdownErrors = append(s.shutdownErrors, err)
+		s.mu.Unlock()
+		return
+	}
+	h.seq, h.f = s.hookSeq, f
+	s.hookSeq++
+	if group != "" {
+		id, ok := s.hookGroups[group]
+		if !ok {
+			if s.hookGroups == nil {
+				s.hookGroups = make(map[string]uint32)
+			}
+			id = uint32(len(s.hookGroups) + 1)
+			s.hookGroups[group] = id
+		}
+		h.group = id
+	}
+	if ctx == nil {
+		s.hooks = append(s.hooks, h)
+		s.mu.Unlock()
+		return
+	}
+	if s.scopedHooks == nil {
+		s.scopedHooks = make(map[uint64]hook)
+	}
+	s.scopedHooks[h.seq] = h
+	s.mu.Unlock()
+	context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		delete(s.scopedHooks, h.seq)
+		s.mu.Unlock()
+	})
+}
+
+// takeHooks marks the shutdown functions as started and returns them in
+// the order they should be run, with the critical functions separately.
+func (s *Service) takeHooks() (funcs, critical []func()) {
+	s.mu.Lock()
+	hooks := s.hooks
+	for _, h := range s.scopedHooks {
+		hooks = append(hooks, h)
+	}
+	sorted := len(s.scopedHooks) == 0
+	grouped := s.hookGroups != nil
+	s.hooks = nil
+	s.scopedHooks = nil
+	s.hooksStarted = true
+	s.mu.Unlock()
+
+	if !sorted {
+		sort.Slice(hooks, func(i, j int) bool { return hooks[i].seq < hooks[j].seq })
+	}
+	others := hooks[:0]
+	for _, h := range hooks {
+		if h.critical {
+			critical = append(critical, h.f)
+		} else {
+			others = append(others, h)
+		}
+	}
+	hooks = others
+	for i, j := 0, len(critical)-1; i < j; i, j = i+1, j-1 {
+		critical[i], critical[j] = critical[j], critical[i]
+	}
+	funcs = make([]func(), 0, len(hooks))
+	if !grouped {
+		for i := len(hooks) - 1; i >= 0; i-- {
+			funcs = append(funcs, hooks[i].f)
+		}
+		return funcs, critical
+	}
+
+	// Split the functions into their groups, most recently registered
+	// first, then take one from each group in turn.
+	var order []uint32
+	groups := make(map[uint32][]func())
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if _, ok := groups[h.group]; !ok {
+			order = append(order, h.group)
+		}
+		groups[h.group] = append(groups[h.group], h.f)
+	}
+	for len(funcs) < len(hooks) {
+		for _, g := range order {
+			if fs := groups[g]; len(fs) > 0 {
+				funcs = append(funcs, fs[0])
+				groups[g] = fs[1:]
+			}
+		}
+	}
+	return funcs, critical
+}
+
+// runHooks runs the shutdown functions, followed by the critical ones.
+// If there are critical functions and the shutdown budget is limited,
+// a function that is still running once only the reserved part of the
+// budget is left is abandoned, and the rest are skipped.
+func (s *Service) runHooks(funcs, critical []func()) {
+	for i, f := range funcs {
+		deadline, ok := s.budget.criticalStart()
+		if len(critical) == 0 || !ok {
+			s.runHook(f)
+			continue
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.runHook(f)
+		}()
+		t := time.NewTimer(time.Until(deadline))
+		select {
+		case <-done:
+			t.Stop()
+			continue
+		case <-t.C:
+		}
+		s.addShutdownError(fmt.Errorf("shutdown function overran the shutdown budget; %d later shutdown functions skipped to run the critical ones", len(funcs)-i-1))
+		break
+	}
+	for _, f := range critical {
+		s.runHook(f)
+	}
+}
+
+// runHook runs a shutdown function, charging it to the shutdown budget.
+func (s *Service) runHook(f func()) {
+	start := time.Now()
+	s.budget.runHook(f)
+	s.publishLifecycle(HookCompleted{Time: time.Now(), Duration: time.Since(start)})
+}
@@ -0,0 +1,182 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOnShutdownScoped(t *testing.T) {
+	_, svc := NewService(context.Background())
+	var ops []string
+	svc.OnShutdown(func() { ops = append(ops, "shutdown-1") })
+	connCtx, cancelConn := context.WithCancel(context.Background())
+	svc.OnShutdownScoped(connCtx, func() { ops = append(ops, "conn-1") })
+	liveCtx, cancelLive := context.WithCancel(context.Background())
+	defer cancelLive()
+	svc.OnShutdownScoped(liveCtx, func() { ops = append(ops, "conn-2") })
+	svc.OnShutdown(func() { ops = append(ops, "shutdown-2") })
+	if n := svc.HookCount(); n != 4 {
+		t.Error("unexpected hook count:", n)
+	}
+
+	// The scoped function is discarded asynchronously once its context
+	// is done.
+	cancelConn()
+	deadline := time.Now().Add(5 * time.Second)
+	for svc.HookCount() != 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	svc.Wait()
+	want := []string{"shutdown-2", "conn-2", "shutdown-1"}
+	if len(ops) != len(want) {
+		t.Fatal("unexpected operations:", ops)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Fatal("unexpected operations:", ops)
+		}
+	}
+	if n := svc.HookCount(); n != 0 {
+		t.Error("unexpected hook count:", n)
+	}
+}
+
+func TestMaxHooks(t *testing.T) {
+	_, svc := New(context.Background(), WithMaxHooks(1))
+	var ops []string
+	svc.OnShutdown(func() { ops = append(ops, "first") })
+	svc.OnShutdown(func() { ops = append(ops, "dropped") })
+	svc.OnShutdownScoped(context.Background(), func() { ops = append(ops, "scoped") })
+	if n := svc.HookCount(); n != 2 {
+		t.Error("unexpected hook count:", n)
+	}
+	svc.Shutdown(nil)
+	svc.Wait()
+	if len(ops) != 2 || ops[0] != "scoped" || ops[1] != "first" {
+		t.Error("unexpected operations:", ops)
+	}
+	errs := svc.ShutdownErrors()
+	if len(errs) != 1 {
+		t.Fatal("unexpected shutdown errors:", errs)
+	}
+	if err, ok := errs[0].(*MisuseError); !ok || err.Method != "OnShutdown" {
+		t.Errorf("unexpected shutdown error %#v", errs[0])
+	}
+}
+
+func TestMaxHooksStrict(t *testing.T) {
+	_, svc := New(context.Background(), WithMaxHooks(1), WithStrict())
+	svc.OnShutdown(func() {})
+	expectMisuse(t, "OnShutdown", func() { svc.OnShutdown(func() {}) })
+	svc.OnShutdownScoped(context.Background(), func() {})
+}
+
+func TestMaxHooksUnlimited(t *testing.T) {
+	_, svc := New(context.Background(), WithMaxHooks(-1))
+	for i := 0; i < 3; i++ {
+		svc.OnShutdown(func() {})
+	}
+	if n := svc.HookCount(); n != 3 {
+		t.Error("unexpected hook count:", n)
+	}
+}
+
+func TestOnShutdownGroup(t *testing.T) {
+	_, svc := NewService(context.Background())
+	var ops []string
+	add := func(group, op string) {
+		svc.OnShutdownGroup(group, func() { ops = append(ops, op) })
+	}
+	add("conns", "conn-1")
+	add("conns", "conn-2")
+	add("wal", "flush")
+	add("conns", "conn-3")
+	svc.OnShutdown(func() { ops = append(ops, "other") })
+	svc.Shutdown(nil)
+	svc.Wait()
+	want := []string{"other", "conn-3", "flush", "conn-2", "conn-1"}
+	if len(ops) != len(want) {
+		t.Fatal("unexpected operations:", ops)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Fatal("unexpected operations:", ops)
+		}
+	}
+}
+
+func TestOnShutdownCritical(t *testing.T) {
+	_, svc := New(context.Background(), WithShutdownTimeout(100*time.Millisecond))
+	var ops []string
+	svc.OnShutdownCritical(50*time.Millisecond, func(ctx context.Context) error {
+		ops = append(ops, "sync wal")
+		return nil
+	})
+	svc.OnShutdown(func() { ops = append(ops, "skipped") })
+	release := make(chan struct{})
+	defer close(release)
+	svc.OnShutdown(func() { <-release })
+	if r := svc.ShutdownBudget().Reserved(); r != 50*time.Millisecond {
+		t.Error("unexpected reserved budget:", r)
+	}
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if len(ops) != 1 || ops[0] != "sync wal" {
+		t.Error("unexpected operations:", ops)
+	}
+	if errs := svc.ShutdownErrors(); len(errs) != 1 || !strings.Contains(errs[0].Error(), "1 later shutdown functions skipped") {
+		t.Error("unexpected shutdown errors:", errs)
+	}
+}
+
+func TestOnShutdownCriticalNegativeReserve(t *testing.T) {
+	_, svc := New(context.Background(), WithShutdownTimeout(100*time.Millisecond))
+	var ops []string
+	svc.OnShutdownCritical(50*time.Millisecond, func(ctx context.Context) error {
+		ops = append(ops, "sync wal")
+		return nil
+	})
+	svc.OnShutdownCritical(-40*time.Millisecond, func(ctx context.Context) error {
+		ops = append(ops, "release lease")
+		return nil
+	})
+	if r := svc.ShutdownBudget().Reserved(); r != 50*time.Millisecond {
+		t.Error("unexpected reserved budget:", r)
+	}
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if len(ops) != 2 || ops[0] != "release lease" || ops[1] != "sync wal" {
+		t.Error("unexpected operations:", ops)
+	}
+}
+
+func TestOnShutdownContext(t *testing.T) {
+	_, svc := New(context.Background(), WithHookTimeout(10*time.Millisecond))
+	svc.OnShutdownContext(func(ctx context.Context) error {
+		return nil
+	})
+	svc.OnShutdownContext(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if errs := svc.ShutdownErrors(); len(errs) != 1 || errs[0] != context.DeadlineExceeded {
+		t.Error("unexpected shutdown errors:", errs)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ServeHTTPServer runs the given HTTP server in a goroutine of the
+// service, serving on the given listener or, if the listener is nil,
+// listening on the server's Addr.
+//
+// When the service shuts down a shutdown function gracefully shuts down
+// the server, allowing the requests in progress up to the given grace
+// period, and no longer than the service's remaining shutdown budget, to
+// finish before the server is closed. If the grace period is zero only
+// the shutdown budget applies. The server stopping because of the
+// shutdown is not treated as an error.
+func (s *Service) ServeHTTPServer(srv *http.Server, l net.Listener, grace time.Duration) {
+	s.OnShutdown(func() {
+		ctx, cancel := s.budget.hookContext(context.Background())
+		defer cancel()
+		if grace > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, grace)
+			defer cancel()
+		}
+		if err := srv.Shutdown(ctx); err != nil {
+			srv.Close()
+		}
+	})
+	s.Go(func() error {
+		var err error
+		if l == nil {
+			err = srv.ListenAndServe()
+		} else {
+			err = srv.Serve(l)
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	})
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServeHTTPServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, svc := NewService(context.Background())
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+	svc.ServeHTTPServer(srv, l, time.Minute)
+
+	respC := make(chan string)
+	go func() {
+		resp, err := http.Get("http://" + l.Addr().String())
+		if err != nil {
+			respC <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		buf, _ := io.ReadAll(resp.Body)
+		respC <- string(buf)
+	}()
+	<-started
+	svc.Shutdown(nil)
+	close(release)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if resp := <-respC; resp != "done" {
+		t.Error("unexpected response:", resp)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrLeaseExpired is returned by a KeepAlive's Refresh function when the
+// registration it refreshes no longer exists, and by Run when a lease
+// expires and there is no Recover function.
+var ErrLeaseExpired = errors.New("lease expired")
+
+// A KeepAlive keeps a registration with a time to live, such as an etcd
+// lease, a Consul session or an entry in an external heartbeat API,
+// alive for as long as a service runs. A KeepAlive is normally run as one
+// of the goroutines of a service:
+//
+//	svc.Go(func() error { return k.Run(ctx) })
+type KeepAlive struct {
+	// TTL is the time to live of the registration. The lease is treated
+	// as expired if it has not been refreshed successfully within the
+	// TTL.
+	TTL time.Duration
+
+	// Interval is the time between refreshes. If this is zero or negative
+	// the lease is refreshed three times per TTL.
+	Interval time.Duration
+
+	// Timeout is the maximum time allowed for each call to Refresh,
+	// Recover or Deregister. If this is zero or negative the Interval is
+	// used.
+	Timeout time.Duration
+
+	// Refresh refreshes the registration. It should return an error
+	// wrapping ErrLeaseExpired if the registration no longer exists.
+	Refresh func(ctx context.Context) error
+
+	// Recover, if set, is called when the lease expires, and would
+	// typically create a new registration. If Recover returns an error,
+	// or there is no Recover function, Run returns the error, or
+	// ErrLeaseExpired.
+	Recover func(ctx context.Context) error
+
+	// Deregister, if set, is called once the context passed to Run is
+	// done to remove the registration.
+	Deregister func(ctx context.Context) error
+
+	// OnError, if set, is called for each refresh that fails without the
+	// lease expiring, and if the final deregistration fails.
+	OnError func(error)
+}
+
+// Run refreshes the lease until the given context is done, at which
+// point the registration is deregistered before Run returns. Run returns
+// an error if the lease expires and cannot be recovered.
+func (k *KeepAlive) Run(ctx context.Context) error {
+	if k.Refresh == nil || k.TTL <= 0 {
+		return errors.New("keepalive: Refresh and TTL must be set")
+	}
+	interval := k.Interval
+	if interval <= 0 {
+		interval = k.TTL / 3
+	}
+	timeout := k.Timeout
+	if timeout <= 0 {
+		timeout = interval
+	}
+	call := func(ctx context.Context, f func(context.Context) error) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return f(ctx)
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	refreshed := time.Now()
+	for {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			if k.Deregister != nil {
+				if err := call(context.WithoutCancel(ctx), k.Deregister); err != nil && k.OnError != nil {
+					k.OnError(err)
+				}
+			}
+			return nil
+		}
+		err := call(ctx, k.Refresh)
+		if err == nil {
+			refreshed = time.Now()
+			continue
+		}
+		if ctx.Err() != nil {
+			continue
+		}
+		if !errors.Is(err, ErrLeaseExpired) && time.Since(refreshed) < k.TTL {
+			if k.OnError != nil {
+				k.OnError(err)
+			}
+			continue
+		}
+		if k.Recover == nil {
+			return ErrLeaseExpired
+		}
+		if err := call(ctx, k.Recover); err != nil {
+			return err
+		}
+		refreshed = time.Now()
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeepAlive(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var refreshes, recoveries int32
+	deregistered := make(chan struct{})
+	k := &KeepAlive{
+		TTL:      30 * time.Millisecond,
+		Interval: 10 * time.Millisecond,
+		Refresh: func(ctx context.Context) error {
+			if atomic.AddInt32(&refreshes, 1) == 2 {
+				return fmt.Errorf("session gone: %w", ErrLeaseExpired)
+			}
+			return nil
+		},
+		Recover: func(ctx context.Context) error {
+			atomic.AddInt32(&recoveries, 1)
+			return nil
+		},
+		Deregister: func(ctx context.Context) error {
+			if ctx.Err() != nil {
+				t.Error("deregistered with a done context")
+			}
+			close(deregistered)
+			return nil
+		},
+	}
+	errC := make(chan error)
+	go func() { errC <- k.Run(ctx) }()
+	for atomic.LoadInt32(&refreshes) < 4 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-errC; err != nil {
+		t.Error("unexpected error:", err)
+	}
+	<-deregistered
+	if n := atomic.LoadInt32(&recoveries); n != 1 {
+		t.Error("unexpected recoveries:", n)
+	}
+}
+
+func TestKeepAliveNegativeInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	refreshed := make(chan error, 1)
+	k := &KeepAlive{
+		TTL:      30 * time.Millisecond,
+		Interval: -time.Second,
+		Timeout:  -time.Second,
+		Refresh: func(ctx context.Context) error {
+			select {
+			case refreshed <- ctx.Err():
+			default:
+			}
+			return nil
+		},
+	}
+	errC := make(chan error)
+	go func() { errC <- k.Run(ctx) }()
+	if err := <-refreshed; err != nil {
+		t.Error("refreshed with a done context:", err)
+	}
+	cancel()
+	if err := <-errC; err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestKeepAliveExpired(t *testing.T) {
+	var errs int32
+	k := &KeepAlive{
+		TTL:      30 * time.Millisecond,
+		Interval: 10 * time.Millisecond,
+		Refresh: func(ctx context.Context) error {
+			return errors.New("test error")
+		},
+		OnError: func(error) { atomic.AddInt32(&errs, 1) },
+	}
+	if err := k.Run(context.Background()); err != ErrLeaseExpired {
+		t.Error("unexpected error:", err)
+	}
+	if atomic.LoadInt32(&errs) == 0 {
+		t.Error("refresh errors not reported")
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// A LifecycleEvent is an event in the lifecycle of a service received by
+// the subscriptions returned by SubscribeLifecycle. It is one of
+// TaskStarted, TaskStopped, ShutdownInitiated, HookCompleted or
+// SignalReceived.
+type LifecycleEvent interface {
+	lifecycleEvent()
+}
+
+// TaskStarted is published when a goroutine of the service starts.
+type TaskStarted struct {
+	Time time.Time
+
+	// Name is the name given to GoNamed, or empty for goroutines
+	// started with Go.
+	Name string
+}
+
+// TaskStopped is published when a goroutine of the service returns.
+type TaskStopped struct {
+	Time time.Time
+	Name string
+
+	// Err is the error returned by the goroutine.
+	Err error
+}
+
+// TaskRestarted is published when a task started with Supervise is
+// restarted.
+type TaskRestarted struct {
+	Time time.Time
+	Name string
+
+	// Err is the error returned by the task, or nil if it returned
+	// successfully.
+	Err error
+}
+
+// ShutdownInitiated is published when the service starts shutting down,
+// before its shutdown functions are run.
+type ShutdownInitiated struct {
+	Time time.Time
+
+	// Cause is the reason for the shutdown, as reported by Err.
+	Cause error
+}
+
+// HookCompleted is published each time a shutdown function returns.
+type HookCompleted struct {
+	Time time.Time
+
+	// Duration is the time the shutdown function took.
+	Duration time.Duration
+}
+
+// SignalReceived is published when the service receives a signal that
+// it handles.
+type SignalReceived struct {
+	Time   time.Time
+	Signal os.Signal
+}
+
+func (TaskStarted) lifecycleEvent()       {}
+func (TaskStopped) lifecycleEvent()       {}
+func (TaskRestarted) lifecycleEvent()     {}
+func (ShutdownInitiated) lifecycleEvent() {}
+func (HookCompleted) lifecycleEvent()     {}
+func (SignalReceived) lifecycleEvent()    {}
+
+// lifecycleTopic is the topic lifecycle events are published on.
+var lifecycleTopic = NewTopic[LifecycleEvent]("service.lifecycle")
+
+// SubscribeLifecycle subscribes to the lifecycle events of the service,
+// with a queue of the given size, so that logging, metrics and tracing
+// can observe the service without each hooking into it separately. As
+// with a Bus, events are dropped if the queue is full. Unlike the
+// service's Bus, the subscription stays open while the service shuts
+// down, and is closed once Wait returns. Task events are only published
+// for goroutines started after the first subscription.
+func (s *Service) SubscribeLifecycle(size int) *Subscription[LifecycleEvent] {
+	b := s.lifecycle.Load()
+	if b == nil {
+		s.mu.Lock()
+		if b = s.lifecycle.Load(); b == nil {
+			b = newBus()
+			if s.stopped {
+				b.close()
+			}
+			s.lifecycle.Store(b)
+		}
+		s.mu.Unlock()
+	}
+	return Subscribe(b, lifecycleTopic, size)
+}
+
+// publishLifecycle publishes a lifecycle event, if anything has
+// subscribed to them, and logs and records it if the service was created
+// with WithLogger or WithMetrics.
+func (s *Service) publishLifecycle(ev LifecycleEvent) {
+	if l := s.opts.logger; l != nil {
+		logLifecycle(l, ev)
+	}
+	if m := s.opts.metrics; m != nil {
+		s.recordLifecycle(m, ev)
+	}
+	if b := s.lifecycle.Load(); b != nil {
+		Publish(b, lifecycleTopic, ev)
+	}
+}
+
+// logLifecycle logs a lifecycle event.
+func logLifecycle(l *slog.Logger, ev LifecycleEvent) {
+	switch ev := ev.(type) {
+	case TaskStarted:
+		l.Debug("task started", "task", ev.Name)
+	case TaskStopped:
+		if ev.Err != nil && !errors.Is(ev.Err, context.Canceled) {
+			l.Error("task failed", "task", ev.Name, "error", ev.Err)
+		} else {
+			l.Debug("task stopped", "task", ev.Name)
+		}
+	case TaskRestarted:
+		l.Warn("task restarting", "task", ev.Name, "error", ev.Err)
+	case ShutdownInitiated:
+		l.Info("service shutting down", "cause", ev.Cause)
+	case HookCompleted:
+		l.Debug("shutdown function completed", "duration", ev.Duration)
+	case SignalReceived:
+		l.Info("signal received", "signal", ev.Signal.String())
+	}
+}
+
+// publishTask returns a function that calls f, publishing the lifecycle
+// events for a task with the given name.
+func (s *Service) publishTask(name string, f func() error) func() error {
+	return func() error {
+		s.publishLifecycle(TaskStarted{Time: time.Now(), Name: name})
+		err := f()
+		s.publishLifecycle(TaskStopped{Time: time.Now(), Name: name, Err: err})
+		return err
+	}
+}
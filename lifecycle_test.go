@@ -0,0 +1,74 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSubscribeLifecycle(t *testing.T) {
+	_, svc := NewService(context.Background())
+	sub := svc.SubscribeLifecycle(10)
+	svc.OnShutdown(func() {})
+	svc.GoNamed("worker", func() error {
+		return errors.New("test error")
+	})
+	if err := svc.Wait(); err == nil || err.Error() != "worker: test error" {
+		t.Error("unexpected error:", err)
+	}
+
+	var events []LifecycleEvent
+	for ev := range sub.C {
+		events = append(events, ev)
+	}
+	if len(events) != 4 {
+		t.Fatalf("unexpected events: %#v", events)
+	}
+	if ev, ok := events[0].(TaskStarted); !ok || ev.Name != "worker" {
+		t.Errorf("unexpected event: %#v", events[0])
+	}
+	if ev, ok := events[1].(TaskStopped); !ok || ev.Name != "worker" || ev.Err == nil {
+		t.Errorf("unexpected event: %#v", events[1])
+	}
+	if ev, ok := events[2].(ShutdownInitiated); !ok || ev.Cause == nil {
+		t.Errorf("unexpected event: %#v", events[2])
+	}
+	if _, ok := events[3].(HookCompleted); !ok {
+		t.Errorf("unexpected event: %#v", events[3])
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	_, svc := New(context.Background(), WithLogger(logger))
+	svc.OnShutdown(func() {})
+	svc.GoNamed("worker", func() error {
+		return errors.New("test error")
+	})
+	svc.Wait()
+
+	want := []string{
+		`level=DEBUG msg="task started" task=worker`,
+		`level=ERROR msg="task failed" task=worker error="worker: test error"`,
+		`level=INFO msg="service shutting down" cause="worker: test error"`,
+		`level=DEBUG msg="shutdown function completed"`,
+		`level=ERROR msg="service stopped" error="worker: test error"`,
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected log:\n%s", buf.String())
+	}
+}
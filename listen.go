@@ -0,0 +1,79 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"net"
+	"time"
+)
+
+// A ListenOption configures the listen helpers of a Service.
+type ListenOption func(*listenOptions)
+
+type listenOptions struct {
+	attempts int
+	delay    time.Duration
+}
+
+// WithAddrInUseRetry retries listening up to the given number of
+// attempts, waiting delay between them, while the address is in use.
+// This is useful immediately after an in-place restart, when the
+// previous process may not yet have released the address.
+func WithAddrInUseRetry(attempts int, delay time.Duration) ListenOption {
+	return func(o *listenOptions) {
+		o.attempts = attempts
+		o.delay = delay
+	}
+}
+
+// Listen announces on the local network address in the same way as
+// net.Listen. The listener is closed when the service shuts down.
+func (s *Service) Listen(network, address string, opts ...ListenOption) (net.Listener, error) {
+	var l net.Listener
+	err := s.listen(opts, func() (err error) {
+		l, err = net.Listen(network, address)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.addListenAddr(l.Addr())
+	s.OnShutdown(func() { l.Close() })
+	return l, nil
+}
+
+// ListenAddrs returns the addresses bound by the listen helpers of the
+// service, so that the actual port can be discovered when listening on
+// port 0.
+func (s *Service) ListenAddrs() []net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]net.Addr(nil), s.listenAddrs...)
+}
+
+func (s *Service) addListenAddr(addr net.Addr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listenAddrs = append(s.listenAddrs, addr)
+}
+
+// listen calls f, retrying according to the given options.
+func (s *Service) listen(opts []ListenOption, f func() error) error {
+	var o listenOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= o.attempts || !isAddrInUse(err) {
+			return err
+		}
+		t := time.NewTimer(o.delay)
+		select {
+		case <-s.doneC:
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestListen(t *testing.T) {
+	_, svc := NewService(context.Background())
+	l, err := svc.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs := svc.ListenAddrs()
+	if len(addrs) != 1 || addrs[0].String() != l.Addr().String() {
+		t.Error("unexpected listen addresses:", addrs)
+	}
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	svc.Wait()
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestListenAddrInUseRetry(t *testing.T) {
+	_, svc := NewService(context.Background())
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Listen("tcp", busy.Addr().String()); !isAddrInUse(err) {
+		t.Fatal("unexpected error:", err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		busy.Close()
+	}()
+	l, err := svc.Listen("tcp", busy.Addr().String(), WithAddrInUseRetry(100, 5*time.Millisecond))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	l.Close()
+}
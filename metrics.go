@@ -0,0 +1,52 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Metrics receives the lifecycle metrics of a service created with
+// WithMetrics. It is implemented by adapters to metrics libraries, such
+// as Prometheus, so that the service does not depend on any of them.
+// Labels are given as pairs of names and values. The methods are called
+// synchronously by the goroutines of the service, so must be safe for
+// concurrent use and should not block.
+//
+// The metrics reported are:
+//
+//	service_goroutines                 gauge of the goroutines running
+//	service_restarts_total             counter of task restarts by Supervise, labeled by task
+//	service_signals_received_total     counter of signals received, labeled by signal
+//	service_hook_duration_seconds      time taken by each shutdown function
+//	service_shutdown_duration_seconds  time taken to shut down
+//	service_state_duration_seconds     time spent in each state, labeled by state, reported on leaving it
+type Metrics interface {
+	IncCounter(name string, labels ...string)
+	SetGauge(name string, value float64, labels ...string)
+	ObserveDuration(name string, d time.Duration, labels ...string)
+}
+
+// WithMetrics reports the lifecycle metrics of the service to m.
+func WithMetrics(m Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// recordLifecycle records the metrics for a lifecycle event.
+func (s *Service) recordLifecycle(m Metrics, ev LifecycleEvent) {
+	switch ev := ev.(type) {
+	case TaskStarted:
+		m.SetGauge("service_goroutines", float64(atomic.AddInt64(&s.running, 1)))
+	case TaskStopped:
+		m.SetGauge("service_goroutines", float64(atomic.AddInt64(&s.running, -1)))
+	case TaskRestarted:
+		m.IncCounter("service_restarts_total", "task", ev.Name)
+	case HookCompleted:
+		m.ObserveDuration("service_hook_duration_seconds", ev.Duration)
+	case SignalReceived:
+		m.IncCounter("service_signals_received_total", "signal", ev.Signal.String())
+	}
+}
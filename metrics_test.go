@@ -0,0 +1,81 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testMetrics struct {
+	mu        sync.Mutex
+	counters  map[string]int
+	gauges    map[string]float64
+	durations map[string]int
+}
+
+func (m *testMetrics) key(name string, labels []string) string {
+	return strings.Join(append([]string{name}, labels...), ",")
+}
+
+func (m *testMetrics) IncCounter(name string, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[m.key(name, labels)]++
+}
+
+func (m *testMetrics) SetGauge(name string, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges[m.key(name, labels)] = value
+}
+
+func (m *testMetrics) ObserveDuration(name string, d time.Duration, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.durations[m.key(name, labels)]++
+}
+
+func TestWithMetrics(t *testing.T) {
+	m := &testMetrics{
+		counters:  make(map[string]int),
+		gauges:    make(map[string]float64),
+		durations: make(map[string]int),
+	}
+	_, svc := New(context.Background(), WithMetrics(m))
+	svc.OnShutdown(func() {})
+	runs := 0
+	svc.Supervise("worker", func(ctx context.Context) error {
+		runs++
+		if runs < 3 {
+			return errors.New("test error")
+		}
+		svc.Shutdown(nil)
+		return nil
+	}, RestartPolicy{InitialBackoff: time.Millisecond})
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+
+	if n := m.counters["service_restarts_total,task,worker"]; n != 2 {
+		t.Error("unexpected restarts:", n)
+	}
+	if v := m.gauges["service_goroutines"]; v != 0 {
+		t.Error("unexpected goroutines:", v)
+	}
+	for _, key := range []string{
+		"service_hook_duration_seconds",
+		"service_shutdown_duration_seconds",
+		"service_state_duration_seconds,state,Starting",
+		"service_state_duration_seconds,state,Running",
+		"service_state_duration_seconds,state,ShuttingDown",
+	} {
+		if m.durations[key] != 1 {
+			t.Errorf("%s observed %d times", key, m.durations[key])
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 Canonical Ltd.
+
+// Package mobile adapts the lifecycle callbacks of mobile applications
+// to services, so that a service embedded in an Android or iOS
+// application, for example through gomobile bindings, pauses while the
+// application is in the background and shuts down gracefully when it is
+// terminated.
+package mobile
+
+import (
+	"sync"
+
+	"github.com/canonical/go-service"
+)
+
+// An Adapter maps application lifecycle callbacks onto a service. Its
+// methods only take and return basic types, so an Adapter can be
+// exported to the application through gomobile bind.
+type Adapter struct {
+	svc           *service.Service
+	pause, resume func()
+
+	mu     sync.Mutex
+	paused bool
+}
+
+// NewAdapter returns an Adapter for the given service. The pause function
+// is called when the application moves to the background, and would
+// typically stop accepting new work and flush buffers; the resume
+// function is called when it returns to the foreground, and would
+// typically refresh connections that may have gone stale. Either function
+// may be nil.
+func NewAdapter(svc *service.Service, pause, resume func()) *Adapter {
+	return &Adapter{svc: svc, pause: pause, resume: resume}
+}
+
+// Background should be called when the application moves to the
+// background. Repeated calls without a call to Foreground in between do
+// nothing.
+func (a *Adapter) Background() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.paused {
+		return
+	}
+	a.paused = true
+	if a.pause != nil {
+		a.pause()
+	}
+}
+
+// Foreground should be called when the application returns to the
+// foreground. It does nothing unless the application was in the
+// background.
+func (a *Adapter) Foreground() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.paused {
+		return
+	}
+	a.paused = false
+	if a.resume != nil {
+		a.resume()
+	}
+}
+
+// Terminate should be called when the application is about to be
+// terminated. It starts shutting down the service without waiting for it
+// to stop; mobile platforms allow little time for termination, so the
+// service should be created with a short WithShutdownTimeout.
+func (a *Adapter) Terminate() {
+	a.svc.Shutdown(nil)
+}
@@ -0,0 +1,30 @@
+// Copyright 2021 Canonical Ltd.
+
+package mobile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/canonical/go-service"
+)
+
+func TestAdapter(t *testing.T) {
+	_, svc := service.NewService(context.Background())
+	var ops []string
+	a := NewAdapter(svc,
+		func() { ops = append(ops, "pause") },
+		func() { ops = append(ops, "resume") },
+	)
+	a.Foreground()
+	a.Background()
+	a.Background()
+	a.Foreground()
+	a.Terminate()
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if len(ops) != 2 || ops[0] != "pause" || ops[1] != "resume" {
+		t.Error("unexpected operations:", ops)
+	}
+}
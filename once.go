@@ -0,0 +1,87 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// A RunCondition determines when a task registered with RunOnce runs.
+type RunCondition struct {
+	kind   string
+	marker func() (string, error)
+}
+
+// OncePerBoot returns a RunCondition that runs a task the first time the
+// service starts after the host boots. It is only supported on platforms
+// that report a boot ID, such as Linux.
+func OncePerBoot() RunCondition {
+	return RunCondition{kind: "boot", marker: bootID}
+}
+
+// OnVersionChange returns a RunCondition that runs a task the first time
+// the service starts with the given version.
+func OnVersionChange(version string) RunCondition {
+	return RunCondition{kind: "version", marker: func() (string, error) {
+		return version, nil
+	}}
+}
+
+// bootID returns an identifier that changes every time the host boots.
+// It is a variable so that tests can simulate a reboot.
+var bootID = func() (string, error) {
+	buf, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return "", errors.New("boot ID not available: " + err.Error())
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+// RunOnce calls the given task immediately if the run condition has not
+// been met since the task last ran successfully, recording a marker in
+// the service's checkpoint store, set using WithCheckpointStore, once it
+// succeeds. If the task fails the marker is not updated, so the task is
+// tried again the next time the service starts. Errors are returned as a
+// *TaskError with the given name.
+func (s *Service) RunOnce(name string, cond RunCondition, task func(ctx context.Context) error) error {
+	fail := func(err error) error {
+		return s.taskError(name, err)
+	}
+	store := s.opts.checkpoints
+	if store == nil {
+		return fail(errors.New("no checkpoint store"))
+	}
+	marker, err := cond.marker()
+	if err != nil {
+		return fail(err)
+	}
+	ctx := context.WithoutCancel(s.ctx)
+	key := name + "." + cond.kind
+	r, err := store.Load(ctx, key)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fail(err)
+	default:
+		buf, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return fail(err)
+		}
+		if string(buf) == marker {
+			return nil
+		}
+	}
+	if err := task(s.ctx); err != nil {
+		return fail(err)
+	}
+	if err := store.Save(ctx, key, strings.NewReader(marker)); err != nil {
+		return fail(err)
+	}
+	return nil
+}
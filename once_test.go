@@ -0,0 +1,64 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRunOnceVersion(t *testing.T) {
+	store := DirCheckpointStore(t.TempDir())
+	var runs []string
+	run := func(version string, err error) error {
+		_, svc := New(context.Background(), WithCheckpointStore(store))
+		defer func() { svc.Shutdown(nil); svc.Wait() }()
+		return svc.RunOnce("migrate", OnVersionChange(version), func(context.Context) error {
+			runs = append(runs, version)
+			return err
+		})
+	}
+	if err := run("1.0", nil); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	run("1.0", nil)
+	err := run("1.1", errors.New("test error"))
+	if err.Error() != "migrate: test error" {
+		t.Error("unexpected error:", err)
+	}
+	if terr, ok := err.(*TaskError); !ok || terr.Time.IsZero() {
+		t.Errorf("unexpected error %#v", err)
+	}
+	run("1.1", nil)
+	run("1.1", nil)
+	if len(runs) != 3 || runs[0] != "1.0" || runs[1] != "1.1" || runs[2] != "1.1" {
+		t.Error("unexpected runs:", runs)
+	}
+}
+
+func TestRunOncePerBoot(t *testing.T) {
+	defer func(f func() (string, error)) { bootID = f }(bootID)
+	boot := "boot-1"
+	bootID = func() (string, error) { return boot, nil }
+
+	store := DirCheckpointStore(t.TempDir())
+	runs := 0
+	run := func() {
+		_, svc := New(context.Background(), WithCheckpointStore(store))
+		defer func() { svc.Shutdown(nil); svc.Wait() }()
+		if err := svc.RunOnce("cache", OncePerBoot(), func(context.Context) error {
+			runs++
+			return nil
+		}); err != nil {
+			t.Error("unexpected error:", err)
+		}
+	}
+	run()
+	run()
+	boot = "boot-2"
+	run()
+	if runs != 2 {
+		t.Error("unexpected runs:", runs)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// OnSignal calls the given function, in a goroutine of the service, each
+// time the given signal is received, until the service starts shutting
+// down. This allows signals such as SIGHUP to trigger actions like
+// reloading configuration or reopening log files. The function is passed
+// the service's context. If it returns an error the service shuts down
+// with that error, as for Go.
+//
+// Receiving the signal does not shut the service down unless the signal
+// is also one of those given to WithSignals.
+func (s *Service) OnSignal(sig os.Signal, f func(ctx context.Context) error) {
+	if s.opts.strict && f == nil {
+		misuse("OnSignal", "called with a nil function")
+	}
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, sig)
+	s.Go(func() error {
+		defer signal.Stop(sigC)
+		for {
+			select {
+			case <-s.doneC:
+				return nil
+			case sig := <-sigC:
+				s.publishLifecycle(SignalReceived{Time: time.Now(), Signal: sig})
+				if err := f(s.ctx); err != nil {
+					return err
+				}
+			}
+		}
+	})
+}
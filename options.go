@@ -0,0 +1,306 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// An Option configures a Service created with New.
+type Option func(*options)
+
+type options struct {
+	signals          []os.Signal
+	immediateSignals []os.Signal
+	ignoredSignals   []os.Signal
+	reloadSignals    []reloadSignal
+	strict           bool
+	metadata         map[string]string
+
+	countSIGPIPE bool
+	sigxfszError bool
+
+	jobControl    bool
+	pause, resume func()
+
+	pollInterval time.Duration
+	poll         func() bool
+
+	grace           time.Duration
+	shutdownTimeout time.Duration
+	hookTimeout     time.Duration
+
+	checkpoints CheckpointStore
+
+	maxHooks int
+
+	recoverPanics    bool
+	collectAllErrors bool
+	panicHandler     func(PanicInfo)
+
+	stopSignal os.Signal
+
+	logger  *slog.Logger
+	metrics Metrics
+
+	// wrapGo and wrapHook, if set, wrap every function passed to Go and
+	// OnShutdown respectively, and start is called once the service has
+	// been created. These are used to implement WithChaos.
+	wrapGo   func(func() error) func() error
+	wrapHook func(func()) func()
+	start    func(*Service)
+}
+
+// WithSignals causes the service to start a shutdown upon receiving any
+// of the given signals.
+func WithSignals(sig ...os.Signal) Option {
+	return func(o *options) {
+		o.signals = append(o.signals, sig...)
+	}
+}
+
+// WithImmediateSignals causes the service to stop immediately upon
+// receiving any of the given signals. The service starts shutting down as
+// for WithSignals, but Wait returns a *SignalError straight away, without
+// waiting for the service's goroutines or shutdown functions, so that the
+// process can exit.
+func WithImmediateSignals(sig ...os.Signal) Option {
+	return func(o *options) {
+		o.immediateSignals = append(o.immediateSignals, sig...)
+	}
+}
+
+// WithIgnoredSignals causes the process to ignore the given signals for as
+// long as it runs.
+func WithIgnoredSignals(sig ...os.Signal) Option {
+	return func(o *options) {
+		o.ignoredSignals = append(o.ignoredSignals, sig...)
+	}
+}
+
+// WithReloadSignal causes the service to call the given function, as for
+// OnSignal, each time the given signal is received.
+func WithReloadSignal(sig os.Signal, f func(ctx context.Context) error) Option {
+	return func(o *options) {
+		o.reloadSignals = append(o.reloadSignals, reloadSignal{sig: sig, f: f})
+	}
+}
+
+// A reloadSignal is a signal given to WithReloadSignal.
+type reloadSignal struct {
+	sig os.Signal
+	f   func(ctx context.Context) error
+}
+
+// WithStrict enables strict mode. In strict mode misuse of the service,
+// such as starting goroutines or registering shutdown functions after
+// Wait has returned, calling Wait more than once, or passing nil
+// functions, causes an immediate panic with a *MisuseError describing the
+// problem, rather than silently misbehaving.
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithMetadata attaches the given key/value metadata to the service. The
+// metadata is reported alongside the instance ID wherever the service
+// identifies itself, such as in beacon heartbeats.
+func WithMetadata(md map[string]string) Option {
+	return func(o *options) {
+		if o.metadata == nil {
+			o.metadata = make(map[string]string, len(md))
+		}
+		for k, v := range md {
+			o.metadata[k] = v
+		}
+	}
+}
+
+// WithSIGPIPECounter causes the service to handle SIGPIPE by counting
+// the signals received and otherwise continuing as normal. The number of
+// signals received is reported by SIGPIPECount. Writes to the broken pipe
+// still fail with EPIPE. This option has no effect on platforms without
+// SIGPIPE.
+func WithSIGPIPECounter() Option {
+	return func(o *options) {
+		o.countSIGPIPE = true
+	}
+}
+
+// WithSIGXFSZError causes the service to handle SIGXFSZ, which Go
+// programs ignore by default so that the write exceeding the file size
+// limit fails with EFBIG, by shutting down with ErrFileSizeLimit. This
+// option has no effect on platforms without SIGXFSZ.
+func WithSIGXFSZError() Option {
+	return func(o *options) {
+		o.sigxfszError = true
+	}
+}
+
+// WithCollectAllErrors causes Wait to return every error returned by the
+// service's goroutines, other than context cancellations, and every
+// shutdown error, joined with errors.Join, rather than only the error
+// that caused the service to stop. The error that caused the service to
+// stop is always first.
+func WithCollectAllErrors() Option {
+	return func(o *options) {
+		o.collectAllErrors = true
+	}
+}
+
+// WithPanicRecovery causes the service to recover panics in goroutines
+// started with Go and in shutdown functions. A panic recovered in a
+// goroutine is returned from the goroutine as a *PanicError, so the
+// service shuts down normally, running its shutdown functions, and the
+// PanicError is returned by Wait. A panic recovered in a shutdown
+// function is reported by ShutdownErrors.
+func WithPanicRecovery() Option {
+	return func(o *options) {
+		o.recoverPanics = true
+	}
+}
+
+// WithPanicHandler recovers panics as WithPanicRecovery does, calling the
+// given function with each panic as it is recovered. This allows crash
+// reporters to capture the panic at the point it was recovered, before
+// it is reported as an error.
+func WithPanicHandler(f func(PanicInfo)) Option {
+	return func(o *options) {
+		o.recoverPanics = true
+		o.panicHandler = f
+	}
+}
+
+// WithJobControl causes the service to handle SIGTSTP and SIGCONT. On
+// SIGTSTP the pause function is called, which would typically stop
+// accepting new work and flush any time sensitive buffers, before the
+// process is stopped. On SIGCONT the resume function is called, which
+// would typically re-arm timers and refresh connections that may have
+// gone stale while the process was stopped. Either function may be nil.
+// This option has no effect on platforms without job control.
+func WithJobControl(pause, resume func()) Option {
+	return func(o *options) {
+		o.jobControl = true
+		o.pause = pause
+		o.resume = resume
+	}
+}
+
+// WithShutdownPoll causes the service to call poll at the given
+// interval, starting a shutdown as if Shutdown had been called with a nil
+// error once it returns true. This is intended for wasm and WASI runtimes,
+// which have no signals, where the host provides a function reporting
+// that the module should stop. Hosts that instead call back into the
+// module must call Shutdown from the callback. If the interval is not
+// positive poll is called every second.
+func WithShutdownPoll(interval time.Duration, poll func() bool) Option {
+	return func(o *options) {
+		o.pollInterval = interval
+		o.poll = poll
+	}
+}
+
+// WithShutdownGrace delays canceling the context returned by New until
+// the given grace period after the service starts shutting down. Shutdown
+// functions still run as soon as shutdown starts, and goroutines can
+// watch the ShuttingDown channel to be notified, giving them the grace
+// period to finish their work before their context is canceled.
+func WithShutdownGrace(d time.Duration) Option {
+	return func(o *options) {
+		o.grace = d
+	}
+}
+
+// WithShutdownTimeout limits the time the service takes to shut down.
+// If the goroutines of the service and the functions registered with
+// OnShutdown have not all completed within the given time of shutdown
+// starting, Wait stops waiting for them and returns a
+// *ShutdownTimeoutError. The timeout is the service's ShutdownBudget,
+// which shutdown functions can use to bound their own work. Any grace
+// period set with WithShutdownGrace should be shorter than the timeout.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.shutdownTimeout = d
+	}
+}
+
+// WithHookTimeout sets the time allowed for each function registered
+// with OnShutdownContext, which is passed a context that expires after
+// this time.
+func WithHookTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.hookTimeout = d
+	}
+}
+
+// WithCheckpointStore sets the store used to save and load the state of
+// components registered with Checkpointable.
+func WithCheckpointStore(store CheckpointStore) Option {
+	return func(o *options) {
+		o.checkpoints = store
+	}
+}
+
+// WithMaxHooks limits the number of shutdown functions that can be
+// waiting to run at once to n, to guard against unbounded memory growth
+// in services that register a shutdown function for every connection.
+// Functions registered with OnShutdownScoped are not counted, as they are
+// discarded when their context is done; per-connection cleanup should be
+// registered that way. A function registered beyond the limit is dropped
+// and a *MisuseError describing it is added to the ShutdownErrors, or, if
+// WithStrict is used, the registration panics with the *MisuseError. If
+// n is zero or negative the number of functions is not limited.
+func WithMaxHooks(n int) Option {
+	return func(o *options) {
+		o.maxHooks = n
+	}
+}
+
+// WithStopSignal declares the signal the platform running the service,
+// such as a container runtime, sends to stop it. If the stop signal is not
+// one of the signals the service shuts down on, given to WithSignals or
+// WithImmediateSignals, the platform's attempts to stop the service
+// gracefully would never be seen, so the service instead shuts down
+// immediately with a *StopSignalError. If sig is nil no check is made.
+func WithStopSignal(sig os.Signal) Option {
+	return func(o *options) {
+		o.stopSignal = sig
+	}
+}
+
+// WithLogger logs the lifecycle of the service to the given logger: the
+// goroutines starting and stopping, the signals received, the start of
+// the shutdown, the time taken by each shutdown function and the error
+// the service stopped with. Routine events are logged at debug level,
+// signals and the shutdown at info level, and errors at error level.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// A MisuseError is the value used to panic when a Service is used
+// incorrectly. Most misuse is only detected for a Service created with
+// WithStrict, but some, such as adding a component twice, releasing a
+// fence that is not held or a non-positive GoEvery interval, always
+// panics.
+type MisuseError struct {
+	// Method is the name of the Service method that was misused.
+	Method string
+
+	// Reason describes the misuse.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *MisuseError) Error() string {
+	return "service: " + e.Method + " " + e.Reason
+}
+
+func misuse(method, reason string) {
+	panic(&MisuseError{Method: method, Reason: reason})
+}
@@ -0,0 +1,41 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStrict(t *testing.T) {
+	_, svc := New(context.Background(), WithStrict())
+	expectMisuse(t, "Go", func() { svc.Go(nil) })
+	expectMisuse(t, "OnShutdown", func() { svc.OnShutdown(nil) })
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+	expectMisuse(t, "Wait", func() { svc.Wait() })
+	expectMisuse(t, "Go", func() { svc.Go(func() error { return nil }) })
+	expectMisuse(t, "OnShutdown", func() { svc.OnShutdown(func() {}) })
+}
+
+func expectMisuse(t *testing.T, method string, f func()) {
+	t.Helper()
+	defer func() {
+		t.Helper()
+		v := recover()
+		err, ok := v.(*MisuseError)
+		if !ok {
+			t.Errorf("expected *MisuseError panic, got %#v", v)
+			return
+		}
+		if err.Method != method {
+			t.Errorf("unexpected method %q in %v", err.Method, err)
+		}
+	}()
+	f()
+}
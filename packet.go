@@ -0,0 +1,117 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// ListenPacket announces on the local network address in the same way as
+// net.ListenPacket. The returned connection is stopped gracefully when the
+// service shuts down: blocked and subsequent reads fail with
+// net.ErrClosed, writes already in progress are allowed to complete, and
+// then the connection is closed. Shutdown waits for the connection to be
+// closed.
+func (s *Service) ListenPacket(network, address string, opts ...ListenOption) (net.PacketConn, error) {
+	var pc net.PacketConn
+	err := s.listen(opts, func() (err error) {
+		pc, err = net.ListenPacket(network, address)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.addListenAddr(pc.LocalAddr())
+	c := &packetConn{PacketConn: pc}
+	if network == "unixgram" && address != "" && address[0] != '@' {
+		c.path = address
+	}
+	c.idle.L = &c.mu
+	s.OnShutdown(c.stop)
+	return c, nil
+}
+
+// packetConn is a net.PacketConn that can be stopped gracefully.
+type packetConn struct {
+	net.PacketConn
+
+	// path is the socket file to remove when the connection is closed.
+	path string
+
+	mu       sync.Mutex
+	idle     sync.Cond
+	writes   int
+	stopping bool
+	closed   bool
+}
+
+// ReadFrom implements net.PacketConn.
+func (c *packetConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	if c.isStopping() {
+		return 0, nil, net.ErrClosed
+	}
+	n, addr, err := c.PacketConn.ReadFrom(p)
+	if err != nil && c.isStopping() {
+		err = net.ErrClosed
+	}
+	return n, addr, err
+}
+
+// WriteTo implements net.PacketConn.
+func (c *packetConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.mu.Lock()
+	if c.stopping {
+		c.mu.Unlock()
+		return 0, net.ErrClosed
+	}
+	c.writes++
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.writes--
+		c.idle.Broadcast()
+		c.mu.Unlock()
+	}()
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+// Close implements net.PacketConn.
+func (c *packetConn) Close() error {
+	c.mu.Lock()
+	c.stopping = true
+	c.mu.Unlock()
+	return c.close()
+}
+
+func (c *packetConn) close() error {
+	err := c.PacketConn.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.path != "" && !c.closed {
+		os.Remove(c.path)
+	}
+	c.closed = true
+	return err
+}
+
+// stop stops reading, waits for any writes in progress and closes the
+// connection.
+func (c *packetConn) stop() {
+	c.mu.Lock()
+	c.stopping = true
+	c.PacketConn.SetReadDeadline(time.Now())
+	for c.writes > 0 {
+		c.idle.Wait()
+	}
+	c.mu.Unlock()
+	c.close()
+}
+
+func (c *packetConn) isStopping() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stopping
+}
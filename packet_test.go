@@ -0,0 +1,62 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestListenPacket(t *testing.T) {
+	_, svc := NewService(context.Background())
+	pc, err := svc.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readErrC := make(chan error, 1)
+	svc.Go(func() error {
+		buf := make([]byte, 64)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				readErrC <- err
+				return nil
+			}
+			if _, err := pc.WriteTo(buf[:n], addr); err != nil {
+				return err
+			}
+		}
+	})
+
+	c, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "ping" {
+		t.Errorf("unexpected response %q", buf[:n])
+	}
+
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+	if err := <-readErrC; !errors.Is(err, net.ErrClosed) {
+		t.Error("unexpected read error:", err)
+	}
+	if _, err := pc.WriteTo([]byte("late"), c.LocalAddr()); !errors.Is(err, net.ErrClosed) {
+		t.Error("unexpected write error:", err)
+	}
+}
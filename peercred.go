@@ -0,0 +1,11 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+// Credentials are the credentials of a process at the other end of a
+// unix socket connection.
+type Credentials struct {
+	PID int
+	UID int
+	GID int
+}
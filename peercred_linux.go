@@ -0,0 +1,40 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"errors"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// PeerCredentials returns the credentials of the process at the other end
+// of a unix socket connection, as reported by SO_PEERCRED. The
+// credentials are those of the peer when the connection was established.
+func PeerCredentials(conn net.Conn) (*Credentials, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, errors.New("peer credentials require a unix socket connection")
+	}
+	rc, err := uc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var cred *unix.Ucred
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		cred, serr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if serr != nil {
+		return nil, serr
+	}
+	return &Credentials{
+		PID: int(cred.Pid),
+		UID: int(cred.Uid),
+		GID: int(cred.Gid),
+	}, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestPeerCredentialsAbstract(t *testing.T) {
+	_, svc := NewService(context.Background())
+	l, err := svc.Listen("unix", "@go-service-test-"+strconv.Itoa(os.Getpid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := net.Dial("unix", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	cred, err := PeerCredentials(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.PID != os.Getpid() || cred.UID != os.Getuid() || cred.GID != os.Getgid() {
+		t.Errorf("unexpected credentials: %+v", cred)
+	}
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	svc.Wait()
+}
+
+func TestListenPacketUnixgramCleanup(t *testing.T) {
+	_, svc := NewService(context.Background())
+	path := filepath.Join(t.TempDir(), "sock")
+	if _, err := svc.ListenPacket("unixgram", path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	svc.Wait()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("socket file not removed:", err)
+	}
+}
@@ -0,0 +1,18 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build !linux
+// +build !linux
+
+package service
+
+import (
+	"errors"
+	"net"
+)
+
+// PeerCredentials returns the credentials of the process at the other end
+// of a unix socket connection. Peer credentials are not supported on this
+// platform.
+func PeerCredentials(conn net.Conn) (*Credentials, error) {
+	return nil, errors.New("peer credentials are not supported on this platform")
+}
@@ -0,0 +1,275 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is the error returned when getting a resource from a pool
+// that has been closed.
+var ErrPoolClosed = errors.New("pool closed")
+
+// A PoolConfig configures a Pool.
+type PoolConfig[T any] struct {
+	// New creates a new resource.
+	New func(ctx context.Context) (T, error)
+
+	// Close, if set, releases a resource that is no longer required.
+	Close func(T) error
+
+	// Check, if set, is called on an idle resource before it is returned
+	// by Get. Resources that fail their check are closed and replaced.
+	Check func(ctx context.Context, v T) error
+
+	// Min is the number of resources the pool keeps open, creating them
+	// in the background if necessary.
+	Min int
+
+	// Max is the maximum number of resources the pool can have open at
+	// once. If this is zero the number of resources is unlimited.
+	Max int
+
+	// IdleTimeout is the time after which idle resources beyond the first
+	// Min are closed. If this is zero or negative idle resources are never
+	// closed.
+	IdleTimeout time.Duration
+}
+
+// A Pool is a pool of reusable resources, such as connections, whose
+// lifecycle is managed by a Service. Idle resources are reaped by one of
+// the goroutines of the service, and the pool is closed when the service
+// shuts down.
+type Pool[T any] struct {
+	cfg PoolConfig[T]
+	sem chan struct{}
+
+	mu     sync.Mutex
+	idle   []idleResource[T]
+	total  int
+	closed bool
+	inUse  sync.WaitGroup
+}
+
+type idleResource[T any] struct {
+	v     T
+	since time.Time
+}
+
+// A PoolStats holds the number of resources in a pool.
+type PoolStats struct {
+	// Idle is the number of resources waiting to be used.
+	Idle int
+
+	// InUse is the number of resources that have been returned by Get
+	// and not yet returned to the pool.
+	InUse int
+}
+
+// NewPool creates a new pool managed by the given service. When the
+// service shuts down the pool is closed and, before the service finishes
+// shutting down, waits for all resources in use to be returned.
+func NewPool[T any](svc *Service, cfg PoolConfig[T]) *Pool[T] {
+	p := &Pool[T]{cfg: cfg}
+	if cfg.Max > 0 {
+		p.sem = make(chan struct{}, cfg.Max)
+	}
+	svc.Go(func() error {
+		p.maintain(svc.ctx)
+		return nil
+	})
+	svc.OnShutdown(p.Close)
+	return p
+}
+
+// Get returns a resource from the pool, creating a new one if there are
+// no idle resources. If the pool has reached its maximum size Get waits
+// for a resource to be returned or for the context to be done. Every
+// resource returned by Get must be given back using either Put or
+// Discard.
+func (p *Pool[T]) Get(ctx context.Context) (T, error) {
+	var zero T
+	if p.sem != nil {
+		select {
+		case p.sem <- struct{}{}:
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			p.release()
+			return zero, ErrPoolClosed
+		}
+		p.inUse.Add(1)
+		if n := len(p.idle); n > 0 {
+			v := p.idle[n-1].v
+			p.idle = p.idle[:n-1]
+			p.mu.Unlock()
+			if p.cfg.Check != nil {
+				if err := p.cfg.Check(ctx, v); err != nil {
+					p.destroy(v)
+					p.inUse.Done()
+					continue
+				}
+			}
+			return v, nil
+		}
+		p.total++
+		p.mu.Unlock()
+
+		v, err := p.cfg.New(ctx)
+		if err != nil {
+			p.mu.Lock()
+			p.total--
+			p.mu.Unlock()
+			p.inUse.Done()
+			p.release()
+			return zero, err
+		}
+		return v, nil
+	}
+}
+
+// Put returns a resource obtained from Get to the pool. If the pool has
+// been closed the resource is closed instead.
+func (p *Pool[T]) Put(v T) {
+	p.mu.Lock()
+	closed := p.closed
+	if !closed {
+		p.idle = append(p.idle, idleResource[T]{v: v, since: time.Now()})
+	}
+	p.mu.Unlock()
+	if closed {
+		p.destroy(v)
+	}
+	p.inUse.Done()
+	p.release()
+}
+
+// Discard closes a resource obtained from Get rather than returning it to
+// the pool, for example because it is broken.
+func (p *Pool[T]) Discard(v T) {
+	p.destroy(v)
+	p.inUse.Done()
+	p.release()
+}
+
+// Close closes all idle resources and waits for all the resources in use
+// to be returned, at which point they are also closed. Close is called
+// automatically when the service managing the pool shuts down.
+func (p *Pool[T]) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+	for _, r := range idle {
+		p.destroy(r.v)
+	}
+	p.inUse.Wait()
+}
+
+// Stats returns the current number of resources in the pool.
+func (p *Pool[T]) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{
+		Idle:  len(p.idle),
+		InUse: p.total - len(p.idle),
+	}
+}
+
+// maintain reaps idle resources and creates the minimum number of
+// resources until the context is done.
+func (p *Pool[T]) maintain(ctx context.Context) {
+	interval := p.cfg.IdleTimeout
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		p.fill(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if p.cfg.IdleTimeout > 0 {
+			p.reap(time.Now().Add(-p.cfg.IdleTimeout))
+		}
+	}
+}
+
+// fill creates idle resources until the pool has the configured minimum.
+func (p *Pool[T]) fill(ctx context.Context) {
+	for {
+		p.mu.Lock()
+		if p.closed || p.total >= p.cfg.Min || (p.cfg.Max > 0 && p.total >= p.cfg.Max) {
+			p.mu.Unlock()
+			return
+		}
+		p.total++
+		p.mu.Unlock()
+		v, err := p.cfg.New(ctx)
+		if err != nil {
+			p.mu.Lock()
+			p.total--
+			p.mu.Unlock()
+			return
+		}
+		p.mu.Lock()
+		closed := p.closed
+		if !closed {
+			p.idle = append(p.idle, idleResource[T]{v: v, since: time.Now()})
+		}
+		p.mu.Unlock()
+		if closed {
+			p.destroy(v)
+		}
+	}
+}
+
+// reap closes resources that have been idle since before the given time,
+// leaving at least the configured minimum open.
+func (p *Pool[T]) reap(before time.Time) {
+	var expired []T
+	p.mu.Lock()
+	// The idle list is in the order resources were returned, so the
+	// oldest resources are at the start.
+	n := 0
+	for n < len(p.idle) && p.idle[n].since.Before(before) && p.total-len(expired) > p.cfg.Min {
+		expired = append(expired, p.idle[n].v)
+		n++
+	}
+	p.idle = append(p.idle[:0], p.idle[n:]...)
+	p.mu.Unlock()
+	for _, v := range expired {
+		p.destroy(v)
+	}
+}
+
+func (p *Pool[T]) destroy(v T) {
+	p.mu.Lock()
+	p.total--
+	p.mu.Unlock()
+	if p.cfg.Close != nil {
+		p.cfg.Close(v)
+	}
+}
+
+func (p *Pool[T]) release() {
+	if p.sem != nil {
+		<-p.sem
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testResource struct {
+	id     int
+	broken bool
+}
+
+type testResources struct {
+	mu     sync.Mutex
+	n      int
+	closed []int
+}
+
+func (r *testResources) config() PoolConfig[*testResource] {
+	return PoolConfig[*testResource]{
+		New: func(context.Context) (*testResource, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.n++
+			return &testResource{id: r.n}, nil
+		},
+		Close: func(v *testResource) error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.closed = append(r.closed, v.id)
+			return nil
+		},
+		Check: func(_ context.Context, v *testResource) error {
+			if v.broken {
+				return errors.New("broken")
+			}
+			return nil
+		},
+	}
+}
+
+func (r *testResources) closedCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.closed)
+}
+
+func TestPoolMax(t *testing.T) {
+	_, svc := New(context.Background())
+	var r testResources
+	cfg := r.config()
+	cfg.Max = 2
+	p := NewPool(svc, cfg)
+
+	v1, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1.id == v2.id {
+		t.Error("same resource returned twice")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx); err != context.DeadlineExceeded {
+		t.Error("unexpected error:", err)
+	}
+	p.Put(v1)
+	v3, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v3 != v1 {
+		t.Error("idle resource not reused")
+	}
+	if s := p.Stats(); s.InUse != 2 || s.Idle != 0 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		p.Put(v2)
+		p.Discard(v3)
+	}()
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+	if n := r.closedCount(); n != 2 {
+		t.Error("unexpected number of closed resources:", n)
+	}
+	if _, err := p.Get(context.Background()); err != ErrPoolClosed {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestPoolCheck(t *testing.T) {
+	_, svc := New(context.Background())
+	var r testResources
+	p := NewPool(svc, r.config())
+	v, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.broken = true
+	p.Put(v)
+	v2, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2 == v {
+		t.Error("broken resource returned")
+	}
+	if n := r.closedCount(); n != 1 {
+		t.Error("broken resource not closed")
+	}
+	p.Put(v2)
+}
+
+func TestPoolReap(t *testing.T) {
+	_, svc := New(context.Background())
+	var r testResources
+	cfg := r.config()
+	cfg.Min = 1
+	cfg.IdleTimeout = 5 * time.Millisecond
+	p := NewPool(svc, cfg)
+	v1, _ := p.Get(context.Background())
+	v2, _ := p.Get(context.Background())
+	p.Put(v1)
+	p.Put(v2)
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Stats().Idle > 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if s := p.Stats(); s.Idle != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+func TestPoolNegativeIdleTimeout(t *testing.T) {
+	_, svc := New(context.Background())
+	var r testResources
+	cfg := r.config()
+	cfg.IdleTimeout = -time.Second
+	p := NewPool(svc, cfg)
+	v, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(v)
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import "context"
+
+// serviceKey is the context key for the service that created a context.
+type serviceKey struct{}
+
+// readOnlyState is the read-only state of a service.
+type readOnlyState struct {
+	on        bool
+	reason    string
+	listeners []func(on bool, reason string)
+}
+
+// SetReadOnly switches the service in or out of read-only mode, giving
+// the reason for the change. Storage services would typically switch to
+// read-only mode when the disk is full or before shutting down. The
+// functions registered with OnReadOnlyChange are called, in the order
+// they were registered, if the mode changes.
+func (s *Service) SetReadOnly(on bool, reason string) {
+	s.mu.Lock()
+	if s.readOnly.on == on {
+		s.readOnly.reason = reason
+		s.mu.Unlock()
+		return
+	}
+	s.readOnly.on = on
+	s.readOnly.reason = reason
+	listeners := s.readOnly.listeners
+	s.mu.Unlock()
+	for _, f := range listeners {
+		f(on, reason)
+	}
+}
+
+// ReadOnly reports whether the service is in read-only mode, and the
+// reason given to SetReadOnly.
+func (s *Service) ReadOnly() (bool, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readOnly.on, s.readOnly.reason
+}
+
+// OnReadOnlyChange registers a function to be called whenever the service
+// switches in or out of read-only mode.
+func (s *Service) OnReadOnlyChange(f func(on bool, reason string)) {
+	if s.opts.strict && f == nil {
+		misuse("OnReadOnlyChange", "called with a nil function")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readOnly.listeners = append(s.readOnly.listeners, f)
+}
+
+// ReadOnlyFromContext reports whether the service that created the given
+// context, which must be derived from the context returned by New, is in
+// read-only mode, and the reason given to SetReadOnly. It returns false
+// if the context was not created by a service.
+func ReadOnlyFromContext(ctx context.Context) (bool, string) {
+	s, ok := ctx.Value(serviceKey{}).(*Service)
+	if !ok {
+		return false, ""
+	}
+	return s.ReadOnly()
+}
@@ -0,0 +1,37 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSetReadOnly(t *testing.T) {
+	ctx, svc := NewService(context.Background())
+	var changes []string
+	svc.OnReadOnlyChange(func(on bool, reason string) {
+		if on {
+			changes = append(changes, "on: "+reason)
+		} else {
+			changes = append(changes, "off: "+reason)
+		}
+	})
+	svc.SetReadOnly(true, "disk full")
+	svc.SetReadOnly(true, "still full")
+	if on, reason := ReadOnlyFromContext(ctx); !on || reason != "still full" {
+		t.Error("unexpected state:", on, reason)
+	}
+	svc.SetReadOnly(false, "space freed")
+	if on, _ := svc.ReadOnly(); on {
+		t.Error("service still read-only")
+	}
+	if len(changes) != 2 || changes[0] != "on: disk full" || changes[1] != "off: space freed" {
+		t.Error("unexpected changes:", changes)
+	}
+	if on, _ := ReadOnlyFromContext(context.Background()); on {
+		t.Error("unexpected read-only state")
+	}
+	svc.Shutdown(nil)
+	svc.Wait()
+}
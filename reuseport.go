@@ -0,0 +1,66 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// ListenReusePort creates the given number of listeners on the same
+// address using SO_REUSEPORT, so that the kernel shares incoming
+// connections between them. Each listener would normally be served by
+// its own goroutine. If the port is 0 all the listeners use the same
+// port. The listeners are all closed at the same time when the service
+// shuts down, so they stop accepting connections together. An error is
+// returned if shards is less than 1.
+func (s *Service) ListenReusePort(network, address string, shards int, opts ...ListenOption) ([]net.Listener, error) {
+	if shards < 1 {
+		return nil, fmt.Errorf("cannot listen on %s: invalid number of shards %d", address, shards)
+	}
+	lc := net.ListenConfig{Control: reusePortControl}
+	ls := make([]net.Listener, 0, shards)
+	closeAll := func() {
+		for _, l := range ls {
+			l.Close()
+		}
+	}
+	for i := 0; i < shards; i++ {
+		var l net.Listener
+		err := s.listen(opts, func() (err error) {
+			l, err = lc.Listen(context.Background(), network, address)
+			return err
+		})
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		if i == 0 {
+			address = l.Addr().String()
+			s.addListenAddr(l.Addr())
+		}
+		ls = append(ls, l)
+	}
+	s.OnShutdown(closeAll)
+	return ls, nil
+}
+
+// ServeReusePort creates the given number of listeners with
+// ListenReusePort and serves each of them in its own goroutine, as
+// ServeConns does. The shards are drained together: when the service
+// shuts down every listener is closed at once and the service waits for
+// the connections of all the shards to finish. The options apply across
+// the shards, so WithMaxConns limits the connections handled by all of
+// them and the returned ConnServer counts them all.
+func (s *Service) ServeReusePort(network, address string, shards int, handle func(ctx context.Context, conn net.Conn) error, opts ...ServeOption) (*ConnServer, error) {
+	ls, err := s.ListenReusePort(network, address, shards)
+	if err != nil {
+		return nil, err
+	}
+	o, cs := newConnServer(opts)
+	for _, l := range ls {
+		s.serveConns(cs, l, handle, o)
+	}
+	return cs, nil
+}
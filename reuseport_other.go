@@ -0,0 +1,17 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package service
+
+import (
+	"errors"
+	"syscall"
+)
+
+// reusePortControl fails as SO_REUSEPORT is not supported on this
+// platform.
+func reusePortControl(_, _ string, _ syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT is not supported on this platform")
+}
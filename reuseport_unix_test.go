@@ -0,0 +1,90 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestListenReusePort(t *testing.T) {
+	_, svc := NewService(context.Background())
+	ls, err := svc.ListenReusePort("tcp", "127.0.0.1:0", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls) != 3 {
+		t.Fatal("unexpected number of listeners:", len(ls))
+	}
+	for _, l := range ls[1:] {
+		if l.Addr().String() != ls[0].Addr().String() {
+			t.Errorf("listener on %s, expected %s", l.Addr(), ls[0].Addr())
+		}
+	}
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	svc.Wait()
+	for _, l := range ls {
+		if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+			t.Error("unexpected error:", err)
+		}
+	}
+}
+
+func TestListenReusePortInvalidShards(t *testing.T) {
+	_, svc := NewService(context.Background())
+	for _, shards := range []int{0, -1} {
+		if _, err := svc.ListenReusePort("tcp", "127.0.0.1:0", shards); err == nil {
+			t.Errorf("no error for %d shards", shards)
+		}
+	}
+	svc.Shutdown(nil)
+	svc.Wait()
+}
+
+func TestServeReusePort(t *testing.T) {
+	_, svc := NewService(context.Background())
+	srv, err := svc.ServeReusePort("tcp", "127.0.0.1:0", 2, func(ctx context.Context, conn net.Conn) error {
+		if _, err := conn.Write([]byte("hello\n")); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs := svc.ListenAddrs()
+	if len(addrs) != 1 {
+		t.Fatal("unexpected listen addresses:", addrs)
+	}
+	var conns []net.Conn
+	for i := 0; i < 4; i++ {
+		conn, err := net.Dial("tcp", addrs[0].String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if _, err := bufio.NewReader(conn).ReadString('\n'); err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+	if n := srv.Active(); n != len(conns) {
+		t.Error("unexpected number of active connections:", n)
+	}
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if n := srv.Active(); n != 0 {
+		t.Error("unexpected number of active connections after shutdown:", n)
+	}
+}
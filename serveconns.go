@@ -0,0 +1,168 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A ServeOption configures ServeConns.
+type ServeOption func(*serveOptions)
+
+type serveOptions struct {
+	maxConns int
+	onError  func(net.Conn, error)
+}
+
+// WithMaxConns limits the number of connections handled at once. Once
+// the limit is reached no more connections are accepted until one of the
+// current connections finishes.
+func WithMaxConns(n int) ServeOption {
+	return func(o *serveOptions) {
+		o.maxConns = n
+	}
+}
+
+// WithConnErrorHandler sets a function called with the errors returned
+// by the connection handler. Errors handling a connection do not stop
+// the service. Without a handler the errors are logged at error level by
+// the logger set with WithLogger, if any, and are otherwise discarded.
+func WithConnErrorHandler(f func(net.Conn, error)) ServeOption {
+	return func(o *serveOptions) {
+		o.onError = f
+	}
+}
+
+// An acceptBackoff is the delay before accepting connections again after
+// an error. The delay starts at 5ms and doubles after every consecutive
+// error, up to one second.
+type acceptBackoff struct {
+	d time.Duration
+}
+
+// wait waits for the next delay, returning false if doneC is closed
+// first.
+func (b *acceptBackoff) wait(doneC <-chan struct{}) bool {
+	if b.d == 0 {
+		b.d = 5 * time.Millisecond
+	} else if b.d *= 2; b.d > time.Second {
+		b.d = time.Second
+	}
+	t := time.NewTimer(b.d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-doneC:
+		return false
+	}
+}
+
+// reset resets the delay after a connection is accepted.
+func (b *acceptBackoff) reset() {
+	b.d = 0
+}
+
+// A ConnServer reports on the connections being handled by ServeConns.
+type ConnServer struct {
+	sem    chan struct{}
+	active atomic.Int64
+}
+
+// Active returns the number of connections currently being handled.
+func (cs *ConnServer) Active() int {
+	return int(cs.active.Load())
+}
+
+// ServeConns accepts connections on the given listener in a goroutine of
+// the service, calling handle in a new goroutine for each connection.
+// The connection is closed when handle returns. The context passed to
+// handle is canceled when handle returns or when the service's context
+// is canceled, whichever happens first.
+//
+// When the service shuts down the listener is closed and the goroutine
+// waits for all the connections being handled to finish. Temporary
+// errors accepting connections are retried with a backoff; any other
+// error from the listener causes the service to shut down.
+func (s *Service) ServeConns(l net.Listener, handle func(ctx context.Context, conn net.Conn) error, opts ...ServeOption) *ConnServer {
+	o, cs := newConnServer(opts)
+	s.OnShutdown(func() { l.Close() })
+	s.serveConns(cs, l, handle, o)
+	return cs
+}
+
+// newConnServer applies the given options and returns a ConnServer
+// enforcing them.
+func newConnServer(opts []ServeOption) (serveOptions, *ConnServer) {
+	var o serveOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	cs := new(ConnServer)
+	if o.maxConns > 0 {
+		cs.sem = make(chan struct{}, o.maxConns)
+	}
+	return o, cs
+}
+
+// serveConns accepts connections on l in a goroutine of the service
+// until l is closed, counting and limiting them with cs.
+func (s *Service) serveConns(cs *ConnServer, l net.Listener, handle func(ctx context.Context, conn net.Conn) error, o serveOptions) {
+	sem := cs.sem
+	s.Go(func() error {
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		var backoff acceptBackoff
+		for {
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+				case <-s.doneC:
+					return nil
+				}
+			}
+			conn, err := l.Accept()
+			if err != nil {
+				if sem != nil {
+					<-sem
+				}
+				if s.IsShuttingDown() && errors.Is(err, net.ErrClosed) {
+					return nil
+				}
+				if isTemporaryAcceptError(err) {
+					if !backoff.wait(s.doneC) {
+						return nil
+					}
+					continue
+				}
+				return err
+			}
+			backoff.reset()
+			wg.Add(1)
+			cs.active.Add(1)
+			go func() {
+				defer wg.Done()
+				defer cs.active.Add(-1)
+				defer conn.Close()
+				if sem != nil {
+					defer func() { <-sem }()
+				}
+				ctx, cancel := context.WithCancel(s.ctx)
+				defer cancel()
+				err := handle(ctx, conn)
+				switch {
+				case err == nil:
+				case o.onError != nil:
+					o.onError(conn, err)
+				case s.opts.logger != nil:
+					s.opts.logger.Error("connection failed", "remote", conn.RemoteAddr().String(), "error", err)
+				}
+			}()
+		}
+	})
+}
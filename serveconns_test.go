@@ -0,0 +1,183 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServeConns(t *testing.T) {
+	_, svc := NewService(context.Background())
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var active, peak, drained int32
+	connErrC := make(chan error, 10)
+	srv := svc.ServeConns(l, func(ctx context.Context, conn net.Conn) error {
+		if n := atomic.AddInt32(&active, 1); n > atomic.LoadInt32(&peak) {
+			atomic.StoreInt32(&peak, n)
+		}
+		defer atomic.AddInt32(&active, -1)
+		if _, err := conn.Write([]byte("hello\n")); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&drained, 1)
+		return errors.New("connection error")
+	}, WithMaxConns(1), WithConnErrorHandler(func(_ net.Conn, err error) {
+		connErrC <- err
+	}))
+
+	c1, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+	if _, err := bufio.NewReader(c1).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Active(); n != 1 {
+		t.Error("unexpected number of active connections:", n)
+	}
+	// The second connection is not handled while the first is active.
+	c2, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+	if n := atomic.LoadInt32(&drained); n != 1 {
+		t.Error("unexpected number of drained connections:", n)
+	}
+	if n := atomic.LoadInt32(&peak); n != 1 {
+		t.Error("connection limit exceeded:", n)
+	}
+	if err := <-connErrC; err.Error() != "connection error" {
+		t.Error("unexpected connection error:", err)
+	}
+	if n := srv.Active(); n != 0 {
+		t.Error("unexpected number of active connections after shutdown:", n)
+	}
+}
+
+func TestServeConnsContext(t *testing.T) {
+	_, svc := NewService(context.Background())
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctxC := make(chan context.Context, 1)
+	srv := svc.ServeConns(l, func(ctx context.Context, conn net.Conn) error {
+		ctxC <- ctx
+		return nil
+	})
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx := <-ctxC
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Error("connection context not canceled after the handler returned")
+	}
+	if svc.IsShuttingDown() {
+		t.Error("service shut down after a connection was handled")
+	}
+	for deadline := time.Now().Add(time.Second); srv.Active() != 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("unexpected number of active connections:", srv.Active())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+// failingListener is a listener whose Accept fails with a temporary error
+// until it is closed.
+type failingListener struct {
+	net.Listener
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	select {
+	case <-l.closed:
+		return nil, net.ErrClosed
+	default:
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Err: timeoutError{}}
+	}
+}
+
+func (l *failingListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+func TestServeConnsBackoffShutdown(t *testing.T) {
+	_, svc := NewService(context.Background())
+	l := &failingListener{closed: make(chan struct{})}
+	svc.ServeConns(l, func(ctx context.Context, conn net.Conn) error {
+		return nil
+	})
+	// Let the backoff grow to several hundred milliseconds.
+	time.Sleep(500 * time.Millisecond)
+	svc.Shutdown(nil)
+	start := time.Now()
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Error("accept backoff held up shutdown for", d)
+	}
+}
+
+func TestServeConnsLogsErrors(t *testing.T) {
+	var buf bytes.Buffer
+	_, svc := New(context.Background(), WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handled := make(chan struct{})
+	svc.ServeConns(l, func(ctx context.Context, conn net.Conn) error {
+		defer close(handled)
+		return errors.New("test error")
+	})
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	<-handled
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if out := buf.String(); !strings.Contains(out, `msg="connection failed"`) || !strings.Contains(out, `error="test error"`) {
+		t.Errorf("connection error not logged: %q", out)
+	}
+}
@@ -5,8 +5,17 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 )
@@ -14,55 +23,203 @@ import (
 // A Service is a service provided by a number of goroutines which will
 // initiate a graceful shutdown when either one of those goroutines errors,
 // or on the receipt of chosen signals.
+//
+// A service belongs to the process that created it. If the process forks,
+// as some daemonization libraries do, the child does not inherit the
+// service's goroutines or signal handlers, so calling Wait in the child
+// panics with a *MisuseError, as does calling Go or any of the methods
+// registering shutdown functions if the service was created with
+// WithStrict. These checks are not made without WithStrict because they
+// add a system call to every Go and OnShutdown. The child should create a
+// new service of its own.
 type Service struct {
-	g *errgroup.Group
+	g        *errgroup.Group
+	rootCtx  context.Context
+	groupCtx context.Context
+	ctx      context.Context
+	opts     options
+	instance string
+	pid      int
+	budget   *ShutdownBudget
+
+	doneC      <-chan struct{}
+	immediateC chan error
+	cancelRoot context.CancelCauseFunc
+	cancelWork context.CancelFunc
 
-	doneC     <-chan struct{}
-	shutdownC chan<- func()
+	mu                sync.Mutex
+	hooks             []hook
+	scopedHooks       map[uint64]hook
+	hookSeq           uint64
+	hookGroups        map[string]uint32
+	hooksStarted      bool
+	waiting           bool
+	stopped           bool
+	shutdownErrors    []error
+	taskErrors        []error
+	listenAddrs       []net.Addr
+	readOnly          readOnlyState
+	activity          map[string]taskActivity
+	startHooks        []func(context.Context) error
+	started           bool
+	readyC            chan struct{}
+	bus               *Bus
+	lifecycle         atomic.Pointer[Bus]
+	components        map[Component]*component
+	pendingComponents []*component
+	state             atomic.Int32
+	stateListeners    []func(old, new State)
+
+	stateMu    sync.Mutex
+	stateSince time.Time
+	running    int64
+	sigpipes   uint64
 }
 
+// A ServiceRunner runs the goroutines of a service and the functions
+// called when it shuts down. It is implemented by *Service, and by the
+// fake in the servicetest package, so that code using a service can be
+// tested in isolation.
+type ServiceRunner interface {
+	// Go calls the given function in a new goroutine.
+	Go(f func() error)
+
+	// OnShutdown registers a function to be called when the service
+	// shuts down.
+	OnShutdown(f func())
+
+	// Shutdown starts shutting down the service, with the given error
+	// being returned by Wait.
+	Shutdown(err error)
+
+	// Wait waits for the service to stop, returning the error that
+	// caused it to stop, if any.
+	Wait() error
+
+	// Clock returns the clock that code using the service should use
+	// to tell the time and wait for it to pass.
+	Clock() Clock
+}
+
+var _ ServiceRunner = (*Service)(nil)
+
 // NewService creates a new service instance using the given context. If
 // any signals are specified the service will start a shutdown upon
 // receiving that signal.
+//
+// NewService is equivalent to calling New with the WithSignals option.
 func NewService(ctx context.Context, sig ...os.Signal) (context.Context, *Service) {
-	g, ctx := errgroup.WithContext(ctx)
+	return New(ctx, WithSignals(sig...))
+}
 
-	if len(sig) > 0 {
+// New creates a new service instance using the given context, configured
+// with the given options.
+func New(ctx context.Context, opts ...Option) (context.Context, *Service) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	rootCtx, cancelRoot := context.WithCancelCause(ctx)
+	g, ctx := errgroup.WithContext(rootCtx)
+	s := &Service{
+		g:          g,
+		rootCtx:    rootCtx,
+		groupCtx:   ctx,
+		ctx:        ctx,
+		opts:       o,
+		instance:   newInstanceID(),
+		pid:        getpid(),
+		budget:     &ShutdownBudget{total: o.shutdownTimeout},
+		doneC:      ctx.Done(),
+		cancelRoot: cancelRoot,
+		cancelWork: func() {},
+		readyC:     make(chan struct{}),
+		stateSince: time.Now(),
+	}
+	s.ctx = context.WithValue(ctx, serviceKey{}, s)
+	context.AfterFunc(ctx, func() { s.setState(StateShuttingDown) })
+	if o.grace > 0 {
+		s.ctx, s.cancelWork = s.graceContext(o.grace)
+	}
+	if o.start != nil {
+		defer o.start(s)
+	}
+
+	if o.stopSignal != nil && !hasSignal(o.signals, o.stopSignal) && !hasSignal(o.immediateSignals, o.stopSignal) {
+		g.Go(func() error {
+			return &StopSignalError{Signal: o.stopSignal}
+		})
+	}
+	if len(o.signals) > 0 {
 		sigC := make(chan os.Signal, 1)
 		g.Go(func() error {
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
 			case sig := <-sigC:
+				s.publishLifecycle(SignalReceived{Time: time.Now(), Signal: sig})
 				return &SignalError{
 					Signal: sig,
 				}
 			}
 		})
-		signal.Notify(sigC, sig...)
+		signal.Notify(sigC, o.signals...)
 	}
-
-	shutdownC := make(chan func())
-	g.Go(func() error {
-		var funcs []func()
-		for {
+	if len(o.immediateSignals) > 0 {
+		s.immediateC = make(chan error, 1)
+		sigC := make(chan os.Signal, 1)
+		g.Go(func() error {
 			select {
-			case f := <-shutdownC:
-				funcs = append(funcs, f)
 			case <-ctx.Done():
-				for i := len(funcs) - 1; i >= 0; i-- {
-					funcs[i]()
-				}
 				return ctx.Err()
+			case sig := <-sigC:
+				s.publishLifecycle(SignalReceived{Time: time.Now(), Signal: sig})
+				err := &SignalError{Signal: sig}
+				s.immediateC <- err
+				return err
 			}
+		})
+		signal.Notify(sigC, o.immediateSignals...)
+	}
+	if len(o.ignoredSignals) > 0 {
+		signal.Ignore(o.ignoredSignals...)
+	}
+	for _, r := range o.reloadSignals {
+		s.OnSignal(r.sig, r.f)
+	}
+	s.handleOSSignals(ctx)
+	if o.poll != nil {
+		interval := o.pollInterval
+		if interval <= 0 {
+			interval = time.Second
 		}
+		g.Go(func() error {
+			t := time.NewTicker(interval)
+			defer t.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-t.C:
+					if o.poll() {
+						s.Shutdown(nil)
+						return nil
+					}
+				}
+			}
+		})
+	}
+
+	g.Go(func() error {
+		<-ctx.Done()
+		s.budget.begin()
+		s.publishLifecycle(ShutdownInitiated{Time: time.Now(), Cause: s.Err()})
+		s.runHooks(s.takeHooks())
+		return ctx.Err()
 	})
 
-	return ctx, &Service{
-		g:         g,
-		doneC:     ctx.Done(),
-		shutdownC: shutdownC,
-	}
+	return s.ctx, s
 }
 
 // Go calls the given function in a new goroutine.
@@ -70,27 +227,538 @@ func NewService(ctx context.Context, sig ...os.Signal) (context.Context, *Servic
 // The first call to return a non-nil error cancels the service; its error
 // will be returned by Wait.
 func (s *Service) Go(f func() error) {
+	s.goTask("Go", "", f)
+}
+
+// goTask starts a goroutine of the service for the given method, with the
+// name given to GoNamed if there is one.
+func (s *Service) goTask(method, name string, f func() error) {
+	if s.opts.strict {
+		s.checkFork(method)
+		if f == nil {
+			misuse(method, "called with a nil function")
+		}
+		if s.isStopped() {
+			misuse(method, "called after Wait returned; start all goroutines before the service stops")
+		}
+	}
+	if s.opts.wrapGo != nil {
+		f = s.opts.wrapGo(f)
+	}
+	if s.opts.recoverPanics {
+		f = s.recoverPanic(name, f)
+	}
+	if s.lifecycle.Load() != nil || s.opts.logger != nil || s.opts.metrics != nil {
+		f = s.publishTask(name, f)
+	}
+	if s.opts.collectAllErrors {
+		f = s.collectError(f)
+	}
 	s.g.Go(f)
 }
 
+// collectError returns a function that calls f, recording any error it
+// returns for a service created with WithCollectAllErrors.
+func (s *Service) collectError(f func() error) func() error {
+	return func() error {
+		err := f()
+		if err != nil && !errors.Is(err, context.Canceled) {
+			s.mu.Lock()
+			s.taskErrors = append(s.taskErrors, err)
+			s.mu.Unlock()
+		}
+		return err
+	}
+}
+
+// GoGrace calls the given function in a new goroutine in the same way as
+// Go. The function is passed a context that is canceled the given grace
+// period after the service starts shutting down, rather than when the
+// service's own context is canceled. This gives the function time to
+// finish its work, such as writing a checkpoint, after it is notified
+// through ShuttingDown that the service is stopping.
+func (s *Service) GoGrace(grace time.Duration, f func(ctx context.Context) error) {
+	if s.opts.strict && f == nil {
+		misuse("GoGrace", "called with a nil function")
+	}
+	s.Go(func() error {
+		ctx, cancel := s.graceContext(grace)
+		defer cancel()
+		return f(ctx)
+	})
+}
+
+// GoNamed calls the given function in a new goroutine in the same way as
+// Go. If the function returns an error it is wrapped in a TaskError with
+// the given name, so that the goroutine that caused the service to shut
+// down can be identified from the error returned by Wait. Errors that
+// only report that the context was canceled are returned unwrapped.
+func (s *Service) GoNamed(name string, f func() error) {
+	if s.opts.strict && f == nil {
+		misuse("GoNamed", "called with a nil function")
+	}
+	s.goTask("GoNamed", name, func() error {
+		err := f()
+		if err == nil || errors.Is(err, context.Canceled) {
+			return err
+		}
+		return s.taskError(name, err)
+	})
+}
+
+// ShuttingDown returns a channel that is closed as soon as the service
+// starts shutting down, before any shutdown functions are run. Unless the
+// service was created with WithShutdownGrace this is when the service's
+// context is canceled.
+func (s *Service) ShuttingDown() <-chan struct{} {
+	return s.doneC
+}
+
+// Err returns nil if the service has not started shutting down. Once it
+// has, Err returns the reason: the error passed to Shutdown or returned
+// by a goroutine of the service, the signal received, or
+// context.Canceled if Shutdown was called with a nil error or the
+// service's parent context was canceled.
+func (s *Service) Err() error {
+	if !s.IsShuttingDown() {
+		return nil
+	}
+	if err := s.cause(); err != nil {
+		return err
+	}
+	return context.Canceled
+}
+
+// IsShuttingDown reports whether the service has started shutting down.
+// It does not take any locks, so it is suitable for calling on every
+// request in a busy server.
+func (s *Service) IsShuttingDown() bool {
+	select {
+	case <-s.doneC:
+		return true
+	default:
+		return false
+	}
+}
+
+// graceContext returns a context that is canceled the given time after
+// the service starts shutting down.
+func (s *Service) graceContext(grace time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(s.ctx))
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.doneC:
+		}
+		t := time.NewTimer(grace)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+		case <-t.C:
+			cancel()
+		}
+	}()
+	return ctx, cancel
+}
+
 // Wait waits for all goroutines started by this service and all functions
 // registered with OnShutdown to complete. The error returned will be the
 // error that caused the service to be canceled, if any.
 func (s *Service) Wait() error {
-	return s.g.Wait()
+	s.checkFork("Wait")
+	s.mu.Lock()
+	if s.opts.strict && s.waiting {
+		s.mu.Unlock()
+		misuse("Wait", "called more than once; only one goroutine should wait for the service")
+	}
+	s.waiting = true
+	s.mu.Unlock()
+
+	s.startup()
+	err := s.wait()
+	if req, ok := context.Cause(s.rootCtx).(*shutdownRequest); ok && errors.Is(err, context.Canceled) {
+		err = req.err
+	}
+	if s.opts.collectAllErrors {
+		err = s.joinErrors(err)
+	}
+	s.cancelWork()
+	s.cancelRoot(nil)
+
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+	s.setState(StateShuttingDown)
+	s.setState(StateStopped)
+	if b := s.lifecycle.Load(); b != nil {
+		b.close()
+	}
+	if m := s.opts.metrics; m != nil {
+		m.ObserveDuration("service_shutdown_duration_seconds", s.budget.Usage().Elapsed)
+	}
+	if l := s.opts.logger; l != nil {
+		if err != nil {
+			l.Error("service stopped", "error", err)
+		} else {
+			l.Info("service stopped")
+		}
+	}
+	return err
+}
+
+// joinErrors joins the error that caused the service to stop with the
+// other errors returned by its goroutines and the shutdown errors.
+func (s *Service) joinErrors(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errs := []error{err}
+	for _, e := range s.taskErrors {
+		if e != err {
+			errs = append(errs, e)
+		}
+	}
+	errs = append(errs, s.shutdownErrors...)
+	if len(errs) == 1 {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown starts shutting down the service in the same way as receiving
+// a signal. The given error, which may be nil, is returned by Wait unless
+// the service had already started shutting down for another reason.
+func (s *Service) Shutdown(err error) {
+	s.cancelRoot(&shutdownRequest{err: err})
+}
+
+// A shutdownRequest is the cause of a shutdown started by Shutdown.
+type shutdownRequest struct {
+	err error
+}
+
+func (r *shutdownRequest) Error() string {
+	if r.err == nil {
+		return "shutdown requested"
+	}
+	return r.err.Error()
+}
+
+// cause returns the error that caused the service to shut down.
+func (s *Service) cause() error {
+	err := context.Cause(s.groupCtx)
+	if req, ok := err.(*shutdownRequest); ok {
+		return req.err
+	}
+	return err
+}
+
+// wait waits for the group to complete, or for the shutdown budget to
+// run out.
+func (s *Service) wait() error {
+	if s.budget.Total() <= 0 && s.immediateC == nil {
+		return s.g.Wait()
+	}
+	waitC := make(chan error, 1)
+	go func() {
+		waitC <- s.g.Wait()
+	}()
+	select {
+	case err := <-waitC:
+		return err
+	case err := <-s.immediateC:
+		return err
+	case <-s.doneC:
+	}
+	var timeoutC <-chan time.Time
+	if s.budget.Total() > 0 {
+		s.budget.begin()
+		t := time.NewTimer(s.budget.Remaining())
+		defer t.Stop()
+		timeoutC = t.C
+	}
+	select {
+	case err := <-waitC:
+		return err
+	case err := <-s.immediateC:
+		return err
+	case <-timeoutC:
+		return &ShutdownTimeoutError{
+			Timeout: s.budget.Total(),
+			Err:     s.cause(),
+		}
+	}
 }
 
 // OnShutdown registers a function to be called when the service determines
 // it is shutting down. The Wait function will wait for all functions
 // provided to OnShutdown to complete before returning.
 func (s *Service) OnShutdown(f func()) {
-	select {
-	case s.shutdownC <- f:
-	case <-s.doneC:
+	if s.opts.strict && s.isStopped() {
+		misuse("OnShutdown", "called after Wait returned; the function would never be waited for")
+	}
+	s.addHook("OnShutdown", nil, "", f)
+}
+
+// InstanceID returns the identifier generated for this instance of the
+// service. The identifier is made from the host name, the process ID and
+// a random component, so it is distinct for every service created.
+func (s *Service) InstanceID() string {
+	return s.instance
+}
+
+// Metadata returns a copy of the metadata the service was created with
+// using WithMetadata.
+func (s *Service) Metadata() map[string]string {
+	md := make(map[string]string, len(s.opts.metadata))
+	for k, v := range s.opts.metadata {
+		md[k] = v
+	}
+	return md
+}
+
+// SIGPIPECount returns the number of SIGPIPE signals received by a
+// service created with WithSIGPIPECounter.
+func (s *Service) SIGPIPECount() uint64 {
+	return atomic.LoadUint64(&s.sigpipes)
+}
+
+// ShutdownErrors returns the errors that occurred while the service was
+// shutting down, such as failures to save checkpoints, which do not
+// change the error returned by Wait.
+func (s *Service) ShutdownErrors() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]error(nil), s.shutdownErrors...)
+}
+
+func (s *Service) addShutdownError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shutdownErrors = append(s.shutdownErrors, err)
+}
+
+// isStopped reports whether Wait has returned.
+func (s *Service) isStopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	var b [4]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b[:]))
+}
+
+// ErrFileSizeLimit is the error returned by Wait when a service created
+// with WithSIGXFSZError shuts down because the process exceeded its file
+// size limit.
+var ErrFileSizeLimit = errors.New("file size limit exceeded")
+
+// A ShutdownTimeoutError is the error returned by Wait when a service
+// created with WithShutdownTimeout does not finish shutting down in time.
+type ShutdownTimeoutError struct {
+	// Timeout is the shutdown timeout that expired.
+	Timeout time.Duration
+
+	// Err is the error that caused the service to shut down.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ShutdownTimeoutError) Error() string {
+	msg := "shutdown timed out after " + e.Timeout.String()
+	if e.Err != nil {
+		msg += " (shutdown caused by: " + e.Err.Error() + ")"
+	}
+	return msg
+}
+
+// Unwrap returns the error that caused the service to shut down.
+func (e *ShutdownTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// recoverPanic returns a function that calls f, returning a *PanicError
+// if f panics, wrapped in a *TaskError if the goroutine has a name.
+func (s *Service) recoverPanic(name string, f func() error) func() error {
+	return func() (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				pe := s.recovered(PanicInfo{Value: v, Stack: debug.Stack(), Task: name})
+				err = pe
+				if name != "" {
+					err = s.taskError(name, pe)
+				}
+			}
+		}()
+		return f()
+	}
+}
+
+// recoverHookPanic returns a function that calls the shutdown function f,
+// recording a *PanicError in the shutdown errors if f panics.
+func (s *Service) recoverHookPanic(f func()) func() {
+	return func() {
+		defer func() {
+			if v := recover(); v != nil {
+				s.addShutdownError(s.recovered(PanicInfo{Value: v, Stack: debug.Stack(), Hook: true}))
+			}
+		}()
 		f()
 	}
 }
 
+// recovered passes a recovered panic to the panic handler, if there is
+// one, and returns the error reporting it.
+func (s *Service) recovered(info PanicInfo) *PanicError {
+	if s.opts.panicHandler != nil {
+		s.opts.panicHandler(info)
+	}
+	return &PanicError{Value: info.Value, Stack: info.Stack}
+}
+
+// PanicInfo describes a panic recovered by a service created with
+// WithPanicRecovery or WithPanicHandler.
+type PanicInfo struct {
+	// Value is the value passed to panic.
+	Value interface{}
+
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack []byte
+
+	// Task is the name of the goroutine that panicked, if it was started
+	// with GoNamed.
+	Task string
+
+	// Hook is true if the panic was in a shutdown function.
+	Hook bool
+}
+
+// A PanicError is the error returned by Wait when a goroutine panics in a
+// service created with WithPanicRecovery, and recorded in the shutdown
+// errors when a shutdown function panics.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack []byte
+}
+
+// Error implements the error interface.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the value passed to panic if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// A TaskError is the error returned by a goroutine started with GoNamed.
+// If the goroutine panicked in a service created with WithPanicRecovery,
+// Err is a *PanicError holding the stack trace.
+type TaskError struct {
+	// Name is the name the goroutine was started with.
+	Name string
+
+	// Err is the error returned by the goroutine.
+	Err error
+
+	// Time is when the goroutine failed.
+	Time time.Time
+
+	// Activity is the activity last reported for the goroutine with
+	// ReportActivity, and ActivityTime is when it was reported. They are
+	// empty if no activity was reported.
+	Activity     string
+	ActivityTime time.Time
+}
+
+// ReportActivity records what the goroutine started with GoNamed with the
+// given name is doing, such as the request or batch it is working on.
+// The most recent activity is attached to the TaskError returned if the
+// goroutine fails, to help diagnose the failure.
+func (s *Service) ReportActivity(name, activity string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.activity == nil {
+		s.activity = make(map[string]taskActivity)
+	}
+	s.activity[name] = taskActivity{what: activity, at: time.Now()}
+}
+
+// A taskActivity is an activity reported with ReportActivity.
+type taskActivity struct {
+	what string
+	at   time.Time
+}
+
+// taskError returns the error for the failure of the named goroutine.
+func (s *Service) taskError(name string, err error) *TaskError {
+	s.mu.Lock()
+	a := s.activity[name]
+	s.mu.Unlock()
+	return &TaskError{
+		Name:         name,
+		Err:          err,
+		Time:         time.Now(),
+		Activity:     a.what,
+		ActivityTime: a.at,
+	}
+}
+
+// Error implements the error interface.
+func (e *TaskError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error returned by the goroutine.
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// A StopSignalError is the error returned by Wait when the stop signal
+// given with WithStopSignal is not one of the signals the service shuts
+// down on.
+type StopSignalError struct {
+	Signal os.Signal
+}
+
+// Error implements the error interface.
+func (e *StopSignalError) Error() string {
+	return "stop signal " + e.Signal.String() + " is not handled by the service"
+}
+
+// StopSignalFromEnv returns the signal named by the STOPSIGNAL
+// environment variable, for use with WithStopSignal. The signal may be
+// given as a name, with or without the SIG prefix, or a number, as in a
+// container image's STOPSIGNAL instruction. If the variable is not set
+// StopSignalFromEnv returns nil.
+func StopSignalFromEnv() (os.Signal, error) {
+	v := os.Getenv("STOPSIGNAL")
+	if v == "" {
+		return nil, nil
+	}
+	return parseSignal(v)
+}
+
+func hasSignal(sigs []os.Signal, sig os.Signal) bool {
+	for _, s := range sigs {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
 // A SignalError is the type of error returned when a Service has shutdown
 // due to receiving a signal.
 type SignalError struct {
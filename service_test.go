@@ -5,42 +5,143 @@ package service
 import (
 	"context"
 	"errors"
-	"os"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
-func TestSignal(t *testing.T) {
-	ctx, svc := NewService(context.Background(), syscall.SIGUSR1)
+func TestServiceError(t *testing.T) {
+	_, svc := NewService(context.Background())
 	svc.Go(func() error {
-		p, err := os.FindProcess(os.Getpid())
-		if err != nil {
-			return err
-		}
-		if err := p.Signal(syscall.SIGUSR1); err != nil {
-			return err
-		}
-		<-ctx.Done()
-		return nil
+		return errors.New("test error")
 	})
 	err := svc.Wait()
-	if err.Error() != "received user defined signal 1" {
+	if err.Error() != "test error" {
 		t.Error("unexpected error:", err)
 	}
 }
 
-func TestServiceError(t *testing.T) {
-	_, svc := NewService(context.Background(), syscall.SIGUSR2)
+func TestShutdownPoll(t *testing.T) {
+	var polls int32
+	ctx, svc := New(context.Background(), WithShutdownPoll(time.Millisecond, func() bool {
+		return atomic.AddInt32(&polls, 1) == 3
+	}))
+	svc.Go(func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if n := atomic.LoadInt32(&polls); n != 3 {
+		t.Error("unexpected polls:", n)
+	}
+}
+
+func TestCollectAllErrors(t *testing.T) {
+	ctx, svc := New(context.Background(), WithCollectAllErrors())
 	svc.Go(func() error {
 		return errors.New("test error")
 	})
+	svc.Go(func() error {
+		<-ctx.Done()
+		return errors.New("second error")
+	})
+	svc.Go(func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	svc.OnShutdownContext(func(context.Context) error {
+		return errors.New("hook error")
+	})
 	err := svc.Wait()
-	if err.Error() != "test error" {
+	if err.Error() != "test error\nsecond error\nhook error" {
+		t.Errorf("unexpected error %q", err)
+	}
+}
+
+func TestGoNamed(t *testing.T) {
+	ctx, svc := NewService(context.Background())
+	svc.GoNamed("waiter", func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	svc.GoNamed("worker", func() error {
+		return errors.New("test error")
+	})
+	err := svc.Wait()
+	var te *TaskError
+	if !errors.As(err, &te) || te.Name != "worker" {
+		t.Fatal("unexpected error:", err)
+	}
+	if err.Error() != "worker: test error" {
 		t.Error("unexpected error:", err)
 	}
 }
 
+func TestReportActivity(t *testing.T) {
+	_, svc := NewService(context.Background())
+	svc.GoNamed("worker", func() error {
+		svc.ReportActivity("worker", "batch 12")
+		return errors.New("test error")
+	})
+	err := svc.Wait()
+	var te *TaskError
+	if !errors.As(err, &te) {
+		t.Fatal("unexpected error:", err)
+	}
+	if te.Activity != "batch 12" || te.ActivityTime.IsZero() || te.Time.Before(te.ActivityTime) {
+		t.Errorf("unexpected task error: %+v", te)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	_, svc := New(context.Background(), WithPanicRecovery())
+	var cleanedUp bool
+	svc.OnShutdown(func() { cleanedUp = true })
+	svc.Go(func() error {
+		panic(errors.New("test error"))
+	})
+	err := svc.Wait()
+	var pe *PanicError
+	if !errors.As(err, &pe) || len(pe.Stack) == 0 {
+		t.Fatal("unexpected error:", err)
+	}
+	if err.Error() != "panic: test error" {
+		t.Error("unexpected error:", err)
+	}
+	if !cleanedUp {
+		t.Error("shutdown function not called")
+	}
+}
+
+func TestPanicHandler(t *testing.T) {
+	var infos []PanicInfo
+	_, svc := New(context.Background(), WithPanicHandler(func(info PanicInfo) {
+		infos = append(infos, info)
+	}))
+	svc.OnShutdown(func() { panic("hook") })
+	svc.GoNamed("worker", func() error { panic("worker") })
+	err := svc.Wait()
+	var pe *PanicError
+	if !errors.As(err, &pe) || err.Error() != "worker: panic: worker" {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(infos) != 2 {
+		t.Fatal("unexpected panics:", infos)
+	}
+	if infos[0].Value != "worker" || infos[0].Task != "worker" || infos[0].Hook || len(infos[0].Stack) == 0 {
+		t.Errorf("unexpected panic: %+v", infos[0])
+	}
+	if infos[1].Value != "hook" || !infos[1].Hook {
+		t.Errorf("unexpected panic: %+v", infos[1])
+	}
+	if errs := svc.ShutdownErrors(); len(errs) != 1 || errs[0].Error() != "panic: hook" {
+		t.Error("unexpected shutdown errors:", errs)
+	}
+}
+
 func TestOnShutdown(t *testing.T) {
 	_, svc := NewService(context.Background())
 	var mu sync.Mutex
@@ -79,3 +180,201 @@ func TestOnShutdown(t *testing.T) {
 		t.Fatal("shutdown operations happened too early", ops)
 	}
 }
+
+func TestInstanceIDAndMetadata(t *testing.T) {
+	md := map[string]string{"region": "eu-west"}
+	_, svc1 := New(context.Background(), WithMetadata(md))
+	_, svc2 := New(context.Background())
+	md["region"] = "changed"
+	if svc1.InstanceID() == "" || svc1.InstanceID() == svc2.InstanceID() {
+		t.Errorf("instance IDs not unique: %q %q", svc1.InstanceID(), svc2.InstanceID())
+	}
+	if got := svc1.Metadata()["region"]; got != "eu-west" {
+		t.Errorf("unexpected metadata: %q", got)
+	}
+	b := svc1.NewBeacon("http://example.com")
+	if b.Instance != svc1.InstanceID() || b.Metadata["region"] != "eu-west" {
+		t.Errorf("unexpected beacon: %+v", b)
+	}
+}
+
+func TestShutdownGrace(t *testing.T) {
+	ctx, svc := New(context.Background(), WithShutdownGrace(20*time.Millisecond))
+	var softAt, hardAt time.Time
+	var softErr error
+	svc.Go(func() error {
+		<-svc.ShuttingDown()
+		softAt = time.Now()
+		softErr = ctx.Err()
+		<-ctx.Done()
+		hardAt = time.Now()
+		return nil
+	})
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+	if softErr != nil {
+		t.Error("context canceled before grace period:", softErr)
+	}
+	if d := hardAt.Sub(softAt); d < 20*time.Millisecond {
+		t.Error("context canceled too soon:", d)
+	}
+}
+
+func TestGoGrace(t *testing.T) {
+	ctx, svc := New(context.Background())
+	var ctxErr, wctxErr error
+	svc.GoGrace(time.Hour, func(wctx context.Context) error {
+		<-svc.ShuttingDown()
+		ctxErr, wctxErr = ctx.Err(), wctx.Err()
+		return nil
+	})
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+	if ctxErr == nil {
+		t.Error("service context not canceled")
+	}
+	if wctxErr != nil {
+		t.Error("worker context canceled before grace period:", wctxErr)
+	}
+}
+
+func TestIsShuttingDown(t *testing.T) {
+	_, svc := NewService(context.Background())
+	if svc.IsShuttingDown() {
+		t.Error("service shutting down before it stopped")
+	}
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	svc.Wait()
+	if !svc.IsShuttingDown() {
+		t.Error("service not shutting down after it stopped")
+	}
+}
+
+func TestErr(t *testing.T) {
+	_, svc := NewService(context.Background())
+	if err := svc.Err(); err != nil {
+		t.Error("unexpected error before shutdown:", err)
+	}
+	svc.Shutdown(errors.New("test error"))
+	<-svc.ShuttingDown()
+	if err := svc.Err(); err == nil || err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+	svc.Wait()
+
+	_, svc = NewService(context.Background())
+	svc.Shutdown(nil)
+	svc.Wait()
+	if err := svc.Err(); err != context.Canceled {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func BenchmarkIsShuttingDown(b *testing.B) {
+	_, svc := NewService(context.Background())
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if svc.IsShuttingDown() {
+				b.Error("service shutting down")
+			}
+		}
+	})
+	b.StopTimer()
+	svc.Go(func() error { return errors.New("done") })
+	svc.Wait()
+}
+
+func BenchmarkGo(b *testing.B) {
+	_, svc := NewService(context.Background())
+	f := func() error { return nil }
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		svc.Go(f)
+	}
+	b.StopTimer()
+	svc.Go(func() error { return errors.New("done") })
+	svc.Wait()
+}
+
+func BenchmarkOnShutdown(b *testing.B) {
+	_, svc := NewService(context.Background())
+	f := func() {}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		svc.OnShutdown(f)
+	}
+	b.StopTimer()
+	svc.Go(func() error { return errors.New("done") })
+	svc.Wait()
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	_, svc := New(context.Background(), WithShutdownTimeout(10*time.Millisecond))
+	blockC := make(chan struct{})
+	defer close(blockC)
+	svc.OnShutdown(func() {
+		<-blockC
+	})
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	err := svc.Wait()
+	var terr *ShutdownTimeoutError
+	if !errors.As(err, &terr) {
+		t.Fatal("unexpected error:", err)
+	}
+	if err.Error() != "shutdown timed out after 10ms (shutdown caused by: test error)" {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestShutdownTimeoutNotExpired(t *testing.T) {
+	_, svc := New(context.Background(), WithShutdownTimeout(time.Hour))
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	ctx, svc := NewService(context.Background())
+	svc.Go(func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+
+	_, svc = NewService(context.Background())
+	svc.Shutdown(errors.New("test error"))
+	svc.Shutdown(errors.New("second error"))
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestShutdownAfterError(t *testing.T) {
+	ctx, svc := NewService(context.Background())
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	<-ctx.Done()
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2021 Canonical Ltd.
+
+// Package servicetest provides helpers for testing code that uses the
+// service package.
+package servicetest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	service "github.com/canonical/go-service"
+)
+
+// A Fake is a fake service.ServiceRunner that runs nothing in the
+// background. Goroutines and shutdown functions registered with a Fake
+// are recorded so that a test can inspect them, and are only run when
+// the test asks for them to be. Time is controlled by the fake's Clock.
+type Fake struct {
+	cancel context.CancelFunc
+	clock  *Clock
+
+	mu       sync.Mutex
+	workers  []func() error
+	next     int
+	hooks    []func()
+	err      error
+	shutdown bool
+}
+
+// New creates a new Fake using the given context. The returned context is
+// canceled when the fake service shuts down. The fake's clock starts at
+// midnight UTC on 1 January 2021.
+func New(ctx context.Context) (context.Context, *Fake) {
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, &Fake{
+		cancel: cancel,
+		clock:  NewClock(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+}
+
+// Clock returns the fake's clock, which only moves when the test
+// advances it.
+func (f *Fake) Clock() service.Clock {
+	return f.clock
+}
+
+// FakeClock returns the fake's clock, so that the test can advance it.
+func (f *Fake) FakeClock() *Clock {
+	return f.clock
+}
+
+// Go records the given function, which is run by RunWorkers or Wait.
+func (f *Fake) Go(fn func() error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.workers = append(f.workers, fn)
+}
+
+// OnShutdown records the given function, which is run by Shutdown. If the
+// fake has already shut down the function is run immediately.
+func (f *Fake) OnShutdown(fn func()) {
+	f.mu.Lock()
+	if f.shutdown {
+		f.mu.Unlock()
+		fn()
+		return
+	}
+	f.hooks = append(f.hooks, fn)
+	f.mu.Unlock()
+}
+
+// Wait runs any goroutines that have not yet been run, shuts down the
+// fake and returns the first error returned by a goroutine, or the error
+// passed to Shutdown.
+func (f *Fake) Wait() error {
+	f.RunWorkers()
+	f.Shutdown(nil)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+// RunWorkers runs, one at a time in the calling goroutine, each function
+// passed to Go that has not yet been run. The first function to return an
+// error shuts down the fake, so subsequent functions are run with a
+// canceled context. Functions that block until the context is done
+// should only be run once the fake has been shut down.
+func (f *Fake) RunWorkers() {
+	for {
+		f.mu.Lock()
+		if f.next == len(f.workers) {
+			f.mu.Unlock()
+			return
+		}
+		fn := f.workers[f.next]
+		f.next++
+		f.mu.Unlock()
+		if err := fn(); err != nil {
+			f.Shutdown(err)
+		}
+	}
+}
+
+// Shutdown shuts down the fake, as happens when a goroutine of a real
+// service fails. The context is canceled and the shutdown functions are
+// run, most recently registered first. If this is the first time the fake
+// has been shut down the given error is the one returned by Wait.
+func (f *Fake) Shutdown(err error) {
+	f.mu.Lock()
+	if f.shutdown {
+		f.mu.Unlock()
+		return
+	}
+	f.shutdown = true
+	f.err = err
+	hooks := f.hooks
+	f.hooks = nil
+	f.mu.Unlock()
+
+	f.cancel()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
+// Workers returns the number of functions that have been passed to Go.
+func (f *Fake) Workers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.workers)
+}
+
+// Hooks returns the number of shutdown functions waiting to be run.
+func (f *Fake) Hooks() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.hooks)
+}
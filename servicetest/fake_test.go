@@ -0,0 +1,89 @@
+// Copyright 2021 Canonical Ltd.
+
+package servicetest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	service "github.com/canonical/go-service"
+	"github.com/canonical/go-service/servicetest"
+)
+
+var _ service.ServiceRunner = (*servicetest.Fake)(nil)
+
+func TestFake(t *testing.T) {
+	ctx, f := servicetest.New(context.Background())
+	var ops []string
+	f.OnShutdown(func() { ops = append(ops, "shutdown-1") })
+	f.OnShutdown(func() { ops = append(ops, "shutdown-2") })
+	f.Go(func() error {
+		ops = append(ops, "go-1")
+		return errors.New("test error")
+	})
+	f.Go(func() error {
+		<-ctx.Done()
+		ops = append(ops, "go-2")
+		return nil
+	})
+	if f.Workers() != 2 || f.Hooks() != 2 {
+		t.Fatalf("unexpected registrations: %d workers, %d hooks", f.Workers(), f.Hooks())
+	}
+	if len(ops) != 0 {
+		t.Fatal("functions run before Wait:", ops)
+	}
+	if err := f.Wait(); err == nil || err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+	want := []string{"go-1", "shutdown-2", "shutdown-1", "go-2"}
+	if len(ops) != len(want) {
+		t.Fatal("unexpected operations:", ops)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Fatal("unexpected operations:", ops)
+		}
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	_, f := servicetest.New(context.Background())
+	var runner service.ServiceRunner = f
+	clock := runner.Clock()
+	start := clock.Now()
+	later := clock.After(time.Hour)
+	sooner := clock.After(time.Minute)
+	select {
+	case <-clock.After(0):
+	default:
+		t.Error("non-positive duration did not elapse immediately")
+	}
+	if n := f.FakeClock().Waiters(); n != 2 {
+		t.Error("unexpected number of waiters:", n)
+	}
+	f.FakeClock().Advance(30 * time.Minute)
+	select {
+	case now := <-sooner:
+		if d := now.Sub(start); d != 30*time.Minute {
+			t.Error("unexpected time elapsed:", d)
+		}
+	default:
+		t.Error("timer not fired after advancing the clock")
+	}
+	select {
+	case <-later:
+		t.Error("timer fired early")
+	default:
+	}
+	f.FakeClock().Advance(30 * time.Minute)
+	select {
+	case <-later:
+	default:
+		t.Error("timer not fired after advancing the clock")
+	}
+	if d := clock.Now().Sub(start); d != time.Hour {
+		t.Error("unexpected time elapsed:", d)
+	}
+}
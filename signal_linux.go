@@ -0,0 +1,63 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// The range of real-time signals available to programs. The kernel's
+// first two real-time signals are reserved by the C library for its
+// threading implementation, so SIGRTMIN is 34, as it is for C programs.
+const (
+	sigRTMin = 34
+	sigRTMax = 64
+)
+
+// RTSignal returns the real-time signal SIGRTMIN+n, numbered as in C
+// programs, for use with WithSignals, OnSignal and the other signal
+// options. For example systemd sends SIGRTMIN+3 to ask a container's
+// init process to shut down. RTSignal panics if SIGRTMIN+n is not a
+// real-time signal.
+func RTSignal(n int) os.Signal {
+	if n < 0 || sigRTMin+n > sigRTMax {
+		panic("service: real-time signal SIGRTMIN+" + strconv.Itoa(n) + " out of range")
+	}
+	return syscall.Signal(sigRTMin + n)
+}
+
+// parseRTSignal parses the name of a real-time signal, such as RTMIN+3
+// or RTMAX-1, without the SIG prefix.
+func parseRTSignal(name string) (os.Signal, bool) {
+	var n int
+	switch {
+	case strings.HasPrefix(name, "RTMIN"):
+		n = sigRTMin
+		name = strings.TrimPrefix(name, "RTMIN")
+		if name != "" && name[0] != '+' {
+			return nil, false
+		}
+	case strings.HasPrefix(name, "RTMAX"):
+		n = sigRTMax
+		name = strings.TrimPrefix(name, "RTMAX")
+		if name != "" && name[0] != '-' {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	if name != "" {
+		off, err := strconv.Atoi(name)
+		if err != nil {
+			return nil, false
+		}
+		n += off
+	}
+	if n < sigRTMin || n > sigRTMax {
+		return nil, false
+	}
+	return syscall.Signal(n), true
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"syscall"
+	"testing"
+)
+
+func TestParseRTSignal(t *testing.T) {
+	tests := []struct {
+		s   string
+		sig syscall.Signal
+	}{
+		{"SIGRTMIN", 34},
+		{"SIGRTMIN+3", 37},
+		{"rtmin+3", 37},
+		{"SIGRTMAX", 64},
+		{"SIGRTMAX-2", 62},
+		{"37", 37},
+	}
+	for _, test := range tests {
+		sig, err := parseSignal(test.s)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.s, err)
+			continue
+		}
+		if sig != test.sig {
+			t.Errorf("%s: got %v, want %v", test.s, sig, test.sig)
+		}
+	}
+	for _, s := range []string{"SIGRTMIN-1", "SIGRTMAX+1", "SIGRTMIN+31", "SIGRTMINX", "SIGRTMIN3"} {
+		if _, err := parseSignal(s); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+	t.Setenv("STOPSIGNAL", "SIGRTMIN+3")
+	if sig, err := StopSignalFromEnv(); err != nil || sig != RTSignal(3) {
+		t.Error("unexpected stop signal:", sig, err)
+	}
+}
+
+func TestRTSignal(t *testing.T) {
+	ctx, svc := NewService(context.Background(), RTSignal(3))
+	svc.Go(func() error {
+		if err := syscall.Kill(syscall.Getpid(), syscall.Signal(37)); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return nil
+	})
+	err := svc.Wait()
+	if serr, ok := err.(*SignalError); !ok || serr.Signal != syscall.Signal(37) {
+		t.Error("unexpected error:", err)
+	}
+}
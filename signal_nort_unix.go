@@ -0,0 +1,14 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build aix || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd netbsd openbsd solaris
+
+package service
+
+import "os"
+
+// parseRTSignal parses the name of a real-time signal. Real-time signals
+// can only be named on Linux.
+func parseRTSignal(name string) (os.Signal, bool) {
+	return nil, false
+}
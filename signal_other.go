@@ -0,0 +1,25 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// parseSignal parses a signal given by name or number. Signals cannot be
+// named on this platform.
+func parseSignal(s string) (os.Signal, error) {
+	return nil, fmt.Errorf("unknown signal %q", s)
+}
+
+// handleOSSignals does nothing on platforms without the signals handled
+// on unix. On platforms without signals at all, such as js and wasip1,
+// the signals given to WithSignals are never received, so services only
+// shut down when a goroutine fails, Shutdown is called or the parent
+// context is canceled.
+func (s *Service) handleOSSignals(ctx context.Context) {}
@@ -0,0 +1,118 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+// signalNames maps the names of signals that are used to stop services
+// to their values.
+var signalNames = map[string]syscall.Signal{
+	"ABRT":  syscall.SIGABRT,
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"KILL":  syscall.SIGKILL,
+	"QUIT":  syscall.SIGQUIT,
+	"TERM":  syscall.SIGTERM,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"WINCH": syscall.SIGWINCH,
+}
+
+// parseSignal parses a signal given by name or number. On Linux
+// real-time signals may be named relative to SIGRTMIN or SIGRTMAX, as in
+// SIGRTMIN+3.
+func parseSignal(s string) (os.Signal, error) {
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+	name := strings.TrimPrefix(strings.ToUpper(s), "SIG")
+	if sig, ok := signalNames[name]; ok {
+		return sig, nil
+	}
+	if sig, ok := parseRTSignal(name); ok {
+		return sig, nil
+	}
+	return nil, fmt.Errorf("unknown signal %q", s)
+}
+
+// handleOSSignals installs the handlers for the platform specific
+// signals selected by the service options.
+func (s *Service) handleOSSignals(ctx context.Context) {
+	if s.opts.countSIGPIPE {
+		sigC := make(chan os.Signal, 1)
+		signal.Notify(sigC, syscall.SIGPIPE)
+		s.g.Go(func() error {
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-sigC:
+					atomic.AddUint64(&s.sigpipes, 1)
+				}
+			}
+		})
+	}
+	if s.opts.sigxfszError {
+		sigC := make(chan os.Signal, 1)
+		signal.Notify(sigC, syscall.SIGXFSZ)
+		s.g.Go(func() error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-sigC:
+				return ErrFileSizeLimit
+			}
+		})
+	}
+	if s.opts.jobControl {
+		sigC := make(chan os.Signal, 1)
+		signal.Notify(sigC, syscall.SIGTSTP, syscall.SIGCONT)
+		s.g.Go(func() error {
+			defer signal.Stop(sigC)
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case sig := <-sigC:
+					if err := s.jobControl(sig); err != nil {
+						return err
+					}
+				}
+			}
+		})
+	}
+}
+
+// stopProcess stops the current process. It is a variable so that tests
+// can avoid stopping themselves.
+var stopProcess = func() error {
+	return syscall.Kill(syscall.Getpid(), syscall.SIGSTOP)
+}
+
+// jobControl handles a SIGTSTP or SIGCONT received by the service.
+// Handling SIGTSTP replaces its default action, so once the pause
+// function has returned the process is stopped with SIGSTOP.
+func (s *Service) jobControl(sig os.Signal) error {
+	if sig == syscall.SIGCONT {
+		if s.opts.resume != nil {
+			s.opts.resume()
+		}
+		return nil
+	}
+	if s.opts.pause != nil {
+		s.opts.pause()
+	}
+	return stopProcess()
+}
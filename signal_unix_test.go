@@ -0,0 +1,213 @@
+// Copyright 2021 Canonical Ltd.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignal(t *testing.T) {
+	ctx, svc := NewService(context.Background(), syscall.SIGUSR1)
+	svc.Go(func() error {
+		p, err := os.FindProcess(os.Getpid())
+		if err != nil {
+			return err
+		}
+		if err := p.Signal(syscall.SIGUSR1); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return nil
+	})
+	err := svc.Wait()
+	if err.Error() != "received user defined signal 1" {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestOnSignal(t *testing.T) {
+	_, svc := NewService(context.Background())
+	reloads := make(chan struct{})
+	svc.OnSignal(syscall.SIGHUP, func(ctx context.Context) error {
+		reloads <- struct{}{}
+		return nil
+	})
+	for i := 0; i < 2; i++ {
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+			t.Fatal(err)
+		}
+		<-reloads
+	}
+	svc.OnSignal(syscall.SIGUSR1, func(ctx context.Context) error {
+		return errors.New("test error")
+	})
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestImmediateSignals(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	reloads := make(chan struct{}, 1)
+	_, svc := New(context.Background(),
+		WithImmediateSignals(syscall.SIGUSR2),
+		WithIgnoredSignals(syscall.SIGTTIN),
+		WithReloadSignal(syscall.SIGHUP, func(context.Context) error {
+			reloads <- struct{}{}
+			return nil
+		}),
+	)
+	if !signal.Ignored(syscall.SIGTTIN) {
+		t.Error("signal not ignored")
+	}
+	svc.OnShutdown(func() { <-release })
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	<-reloads
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Wait(); err.Error() != "received user defined signal 2" {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestSIGPIPECounter(t *testing.T) {
+	ctx, svc := New(context.Background(), WithSIGPIPECounter())
+	svc.Go(func() error {
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGPIPE); err != nil {
+			return err
+		}
+		for svc.SIGPIPECount() == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Millisecond):
+			}
+		}
+		return errors.New("test error")
+	})
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+	if n := svc.SIGPIPECount(); n != 1 {
+		t.Error("unexpected SIGPIPE count:", n)
+	}
+}
+
+func TestSIGXFSZError(t *testing.T) {
+	ctx, svc := New(context.Background(), WithSIGXFSZError())
+	svc.Go(func() error {
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGXFSZ); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return nil
+	})
+	if err := svc.Wait(); err != ErrFileSizeLimit {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestJobControl(t *testing.T) {
+	stopped := make(chan struct{}, 1)
+	defer func(f func() error) { stopProcess = f }(stopProcess)
+	stopProcess = func() error {
+		stopped <- struct{}{}
+		return nil
+	}
+	var paused, resumed int32
+	_, svc := New(context.Background(), WithJobControl(
+		func() { atomic.AddInt32(&paused, 1) },
+		func() { atomic.AddInt32(&resumed, 1) },
+	))
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTSTP); err != nil {
+		t.Fatal(err)
+	}
+	<-stopped
+	if atomic.LoadInt32(&paused) != 1 {
+		t.Error("pause function not called")
+	}
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGCONT); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; atomic.LoadInt32(&resumed) == 0; i++ {
+		if i == 100 {
+			t.Fatal("resume function not called")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestStopSignal(t *testing.T) {
+	_, svc := New(context.Background(),
+		WithSignals(syscall.SIGINT),
+		WithStopSignal(syscall.SIGTERM),
+	)
+	err := svc.Wait()
+	if err.Error() != "stop signal terminated is not handled by the service" {
+		t.Error("unexpected error:", err)
+	}
+
+	_, svc = New(context.Background(),
+		WithSignals(syscall.SIGINT, syscall.SIGTERM),
+		WithStopSignal(syscall.SIGTERM),
+	)
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+
+	_, svc = New(context.Background(),
+		WithImmediateSignals(syscall.SIGTERM),
+		WithStopSignal(syscall.SIGTERM),
+	)
+	svc.Go(func() error {
+		return errors.New("test error")
+	})
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestStopSignalFromEnv(t *testing.T) {
+	for _, v := range []string{"SIGTERM", "term", "15"} {
+		t.Setenv("STOPSIGNAL", v)
+		sig, err := StopSignalFromEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sig != syscall.SIGTERM {
+			t.Errorf("unexpected signal for %q: %v", v, sig)
+		}
+	}
+	t.Setenv("STOPSIGNAL", "SIGBOGUS")
+	if _, err := StopSignalFromEnv(); err == nil {
+		t.Error("expected error")
+	}
+	t.Setenv("STOPSIGNAL", "")
+	if sig, err := StopSignalFromEnv(); sig != nil || err != nil {
+		t.Error("unexpected result:", sig, err)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import "context"
+
+// OnStart registers a function to be called when the service starts.
+// The start functions are called, in the order they were registered, in
+// a goroutine of the service once Wait is called, and the channel
+// returned by Ready is closed once they have all returned successfully.
+// If a start function returns an error the service shuts down with that
+// error and the remaining start functions are not called. Functions
+// registered after Wait has been called are called immediately in a new
+// goroutine of the service.
+func (s *Service) OnStart(f func(ctx context.Context) error) {
+	if s.opts.strict && f == nil {
+		misuse("OnStart", "called with a nil function")
+	}
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		s.Go(func() error { return f(s.ctx) })
+		return
+	}
+	s.startHooks = append(s.startHooks, f)
+	s.mu.Unlock()
+}
+
+// Ready returns a channel that is closed once all the functions
+// registered with OnStart before Wait was called have returned
+// successfully. If there are none it is closed as soon as Wait is called.
+// The channel is never closed if the service shuts down before it is
+// ready.
+func (s *Service) Ready() <-chan struct{} {
+	return s.readyC
+}
+
+// startup starts calling the start functions, if it has not already
+// started.
+func (s *Service) startup() {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = true
+	hooks := s.startHooks
+	s.startHooks = nil
+	s.mu.Unlock()
+
+	s.g.Go(func() error {
+		for _, f := range hooks {
+			if s.IsShuttingDown() {
+				return nil
+			}
+			if err := f(s.ctx); err != nil {
+				return err
+			}
+		}
+		if !s.IsShuttingDown() {
+			close(s.readyC)
+			s.setState(StateRunning)
+		}
+		return nil
+	})
+}
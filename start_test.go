@@ -0,0 +1,60 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestOnStart(t *testing.T) {
+	ctx, svc := NewService(context.Background())
+	var ops []string
+	svc.OnStart(func(context.Context) error {
+		ops = append(ops, "open db")
+		return nil
+	})
+	svc.OnStart(func(context.Context) error {
+		ops = append(ops, "warm cache")
+		return nil
+	})
+	svc.Go(func() error {
+		select {
+		case <-svc.Ready():
+			ops = append(ops, "ready")
+		case <-ctx.Done():
+		}
+		svc.Shutdown(nil)
+		return nil
+	})
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if len(ops) != 3 || ops[0] != "open db" || ops[1] != "warm cache" || ops[2] != "ready" {
+		t.Error("unexpected operations:", ops)
+	}
+}
+
+func TestOnStartError(t *testing.T) {
+	_, svc := NewService(context.Background())
+	called := false
+	svc.OnStart(func(context.Context) error {
+		return errors.New("test error")
+	})
+	svc.OnStart(func(context.Context) error {
+		called = true
+		return nil
+	})
+	if err := svc.Wait(); err.Error() != "test error" {
+		t.Error("unexpected error:", err)
+	}
+	if called {
+		t.Error("start function called after an earlier one failed")
+	}
+	select {
+	case <-svc.Ready():
+		t.Error("service ready after a start function failed")
+	default:
+	}
+}
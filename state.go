@@ -0,0 +1,87 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"strconv"
+	"time"
+)
+
+// A State is a stage in the lifecycle of a service.
+type State int
+
+const (
+	// StateStarting is the state of a service until it is ready, as
+	// reported by Ready.
+	StateStarting State = iota
+
+	// StateRunning is the state of a service once it is ready, until it
+	// starts shutting down.
+	StateRunning
+
+	// StateShuttingDown is the state of a service from when it starts
+	// shutting down until Wait returns.
+	StateShuttingDown
+
+	// StateStopped is the state of a service once Wait has returned.
+	StateStopped
+)
+
+// String returns the name of the state.
+func (st State) String() string {
+	switch st {
+	case StateStarting:
+		return "Starting"
+	case StateRunning:
+		return "Running"
+	case StateShuttingDown:
+		return "ShuttingDown"
+	case StateStopped:
+		return "Stopped"
+	}
+	return "State(" + strconv.Itoa(int(st)) + ")"
+}
+
+// State returns the current state of the service. It does not take any
+// locks, so it is suitable for calling on every request in a busy server.
+func (s *Service) State() State {
+	return State(s.state.Load())
+}
+
+// OnStateChange registers a function to be called whenever the service
+// changes state. States only ever advance, although a service that shuts
+// down before it is ready skips StateRunning. The functions are called,
+// in the order they were registered, by the goroutine causing the
+// change, and calls for one change complete before those for the next
+// begin.
+func (s *Service) OnStateChange(f func(old, new State)) {
+	if s.opts.strict && f == nil {
+		misuse("OnStateChange", "called with a nil function")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stateListeners = append(s.stateListeners, f)
+}
+
+// setState advances the service to the given state, if it is not already
+// in that state or a later one, and calls the state change functions.
+func (s *Service) setState(st State) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	old := s.State()
+	if st <= old {
+		return
+	}
+	s.state.Store(int32(st))
+	s.mu.Lock()
+	listeners := s.stateListeners
+	s.mu.Unlock()
+	if m := s.opts.metrics; m != nil {
+		now := time.Now()
+		m.ObserveDuration("service_state_duration_seconds", now.Sub(s.stateSince), "state", old.String())
+		s.stateSince = now
+	}
+	for _, f := range listeners {
+		f(old, st)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestState(t *testing.T) {
+	_, svc := NewService(context.Background())
+	var mu sync.Mutex
+	var changes []string
+	svc.OnStateChange(func(old, new State) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, old.String()+"->"+new.String())
+	})
+	if st := svc.State(); st != StateStarting {
+		t.Error("unexpected state:", st)
+	}
+	svc.Go(func() error {
+		<-svc.Ready()
+		if st := svc.State(); st != StateRunning {
+			t.Error("unexpected state:", st)
+		}
+		svc.Shutdown(nil)
+		return nil
+	})
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if st := svc.State(); st != StateStopped {
+		t.Error("unexpected state:", st)
+	}
+	want := []string{"Starting->Running", "Running->ShuttingDown", "ShuttingDown->Stopped"}
+	if len(changes) != len(want) {
+		t.Fatal("unexpected state changes:", changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatal("unexpected state changes:", changes)
+		}
+	}
+}
+
+func TestStateShutdownBeforeReady(t *testing.T) {
+	_, svc := NewService(context.Background())
+	var changes []State
+	svc.OnStateChange(func(old, new State) {
+		changes = append(changes, new)
+	})
+	svc.Shutdown(nil)
+	svc.Wait()
+	if len(changes) != 2 || changes[0] != StateShuttingDown || changes[1] != StateStopped {
+		t.Error("unexpected state changes:", changes)
+	}
+}
+
+func BenchmarkState(b *testing.B) {
+	_, svc := NewService(context.Background())
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if svc.State() != StateStarting {
+				b.Error("unexpected state")
+			}
+		}
+	})
+	b.StopTimer()
+	svc.Shutdown(nil)
+	svc.Wait()
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// A RestartMode determines when a supervised task is restarted.
+type RestartMode int
+
+const (
+	// RestartOnFailure restarts a task when it returns an error.
+	RestartOnFailure RestartMode = iota
+
+	// RestartAlways restarts a task whenever it returns.
+	RestartAlways
+
+	// RestartNever never restarts a task, so it behaves as if started
+	// with GoNamed.
+	RestartNever
+)
+
+// A RestartPolicy controls how a task started with Supervise is
+// restarted.
+type RestartPolicy struct {
+	// Mode determines when the task is restarted.
+	Mode RestartMode
+
+	// InitialBackoff is the delay before the first restart. The delay
+	// doubles for each further restart within the Interval, up to
+	// MaxBackoff. If InitialBackoff is zero or negative 100ms is used,
+	// and if MaxBackoff is zero or negative the delay is not limited.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// MaxRestarts is the number of restarts allowed within the Interval.
+	// Once the task would be restarted more often the service shuts down
+	// with the task's last error. If MaxRestarts is zero the number of
+	// restarts is not limited.
+	MaxRestarts int
+	Interval    time.Duration
+}
+
+// maxBackoffShift limits the number of times the restart delay doubles,
+// and the number of restarts remembered when they are not limited. The
+// delay is also clamped as it doubles, so that it cannot overflow.
+const maxBackoffShift = 24
+
+// Supervise runs the given task in a goroutine of the service, restarting
+// it according to the given policy. The task is passed the service's
+// context and is not restarted once the service starts shutting down.
+// Errors that stop the service are wrapped in a *TaskError with the given
+// name.
+func (s *Service) Supervise(name string, task func(ctx context.Context) error, policy RestartPolicy) {
+	if s.opts.strict && task == nil {
+		misuse("Supervise", "called with a nil function")
+	}
+	initial := policy.InitialBackoff
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	s.GoNamed(name, func() error {
+		var restarts []time.Time
+		for {
+			err := task(s.ctx)
+			if s.ctx.Err() != nil {
+				return err
+			}
+			switch {
+			case policy.Mode == RestartNever:
+				return err
+			case policy.Mode == RestartOnFailure && err == nil:
+				return nil
+			}
+
+			now := time.Now()
+			recent := restarts[:0]
+			for _, t := range restarts {
+				if policy.Interval == 0 || now.Sub(t) < policy.Interval {
+					recent = append(recent, t)
+				}
+			}
+			restarts = append(recent, now)
+			if policy.MaxRestarts > 0 && len(restarts) > policy.MaxRestarts {
+				if err == nil {
+					err = errors.New("task returned")
+				}
+				return fmt.Errorf("restarted too often: %w", err)
+			}
+			if keep := max(policy.MaxRestarts, maxBackoffShift); len(restarts) > keep {
+				restarts = restarts[len(restarts)-keep:]
+			}
+
+			s.publishLifecycle(TaskRestarted{Time: now, Name: name, Err: err})
+			t := time.NewTimer(restartBackoff(initial, policy.MaxBackoff, len(restarts)))
+			select {
+			case <-s.ctx.Done():
+				t.Stop()
+				return s.ctx.Err()
+			case <-t.C:
+			}
+		}
+	})
+}
+
+// restartBackoff returns the delay before a restart, given the number of
+// recent restarts including this one.
+func restartBackoff(initial, maxBackoff time.Duration, restarts int) time.Duration {
+	backoff := initial
+	for i := 1; i < restarts && i <= maxBackoffShift; i++ {
+		if backoff > math.MaxInt64/2 {
+			backoff = math.MaxInt64
+			break
+		}
+		backoff *= 2
+	}
+	if maxBackoff > 0 && backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
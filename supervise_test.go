@@ -0,0 +1,98 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSupervise(t *testing.T) {
+	ctx, svc := NewService(context.Background())
+	runs := 0
+	svc.Supervise("worker", func(ctx context.Context) error {
+		runs++
+		if runs < 3 {
+			return errors.New("test error")
+		}
+		return nil
+	}, RestartPolicy{InitialBackoff: time.Millisecond})
+	svc.Go(func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	time.Sleep(50 * time.Millisecond)
+	svc.Shutdown(nil)
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if runs != 3 {
+		t.Error("unexpected runs:", runs)
+	}
+}
+
+func TestSuperviseMaxRestarts(t *testing.T) {
+	_, svc := NewService(context.Background())
+	runs := 0
+	svc.Supervise("worker", func(ctx context.Context) error {
+		runs++
+		return errors.New("test error")
+	}, RestartPolicy{
+		Mode:           RestartAlways,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     2 * time.Millisecond,
+		MaxRestarts:    3,
+		Interval:       time.Minute,
+	})
+	err := svc.Wait()
+	if err.Error() != "worker: restarted too often: test error" {
+		t.Error("unexpected error:", err)
+	}
+	if runs != 4 {
+		t.Error("unexpected runs:", runs)
+	}
+}
+
+func TestSuperviseNegativeInitialBackoff(t *testing.T) {
+	_, svc := NewService(context.Background())
+	var runs []time.Time
+	svc.Supervise("worker", func(ctx context.Context) error {
+		if runs = append(runs, time.Now()); len(runs) == 1 {
+			return errors.New("test error")
+		}
+		svc.Shutdown(nil)
+		return nil
+	}, RestartPolicy{InitialBackoff: -time.Second})
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if len(runs) != 2 {
+		t.Fatal("unexpected runs:", len(runs))
+	}
+	if d := runs[1].Sub(runs[0]); d < 100*time.Millisecond {
+		t.Error("restarted after", d, "rather than the default backoff")
+	}
+}
+
+func TestRestartBackoff(t *testing.T) {
+	tests := []struct {
+		initial, max time.Duration
+		restarts     int
+		want         time.Duration
+	}{
+		{time.Second, 0, 1, time.Second},
+		{time.Second, 0, 3, 4 * time.Second},
+		{time.Second, 5 * time.Second, 4, 5 * time.Second},
+		{10 * time.Minute, 0, 25, math.MaxInt64},
+		{10 * time.Minute, time.Hour, 25, time.Hour},
+		{time.Millisecond, 0, 100, time.Millisecond << maxBackoffShift},
+	}
+	for _, test := range tests {
+		if got := restartBackoff(test.initial, test.max, test.restarts); got != test.want {
+			t.Errorf("restartBackoff(%v, %v, %d) = %v, want %v", test.initial, test.max, test.restarts, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright 2021 Canonical Ltd.
+
+// Package syslog provides a slog.Handler that writes RFC 5424 messages
+// to a local or remote syslog daemon.
+package syslog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// A Facility is a syslog facility.
+type Facility int
+
+// The syslog facilities defined by RFC 5424.
+const (
+	Kern Facility = iota
+	User
+	Mail
+	Daemon
+	Auth
+	Syslog
+	LPR
+	News
+	UUCP
+	Cron
+	AuthPriv
+	FTP
+	_
+	_
+	_
+	_
+	Local0
+	Local1
+	Local2
+	Local3
+	Local4
+	Local5
+	Local6
+	Local7
+)
+
+// The syslog severities used by the handler.
+const (
+	severityError   = 3
+	severityWarning = 4
+	severityInfo    = 6
+	severityDebug   = 7
+)
+
+// localAddrs are the paths searched for the local syslog socket.
+var localAddrs = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// Options configure a Handler.
+type Options struct {
+	// Network and Address are the address of the syslog daemon, with
+	// Network being one of "udp", "tcp" or "unix". If Network is empty
+	// the local syslog socket is used.
+	Network string
+	Address string
+
+	// Facility is the facility messages are logged with. The kernel
+	// facility is reserved for the kernel, so if this is zero Daemon is
+	// used.
+	Facility Facility
+
+	// AppName is the APP-NAME of each message. If this is empty the base
+	// name of the program is used.
+	AppName string
+
+	// Hostname is the HOSTNAME of each message. If this is empty the
+	// host name reported by the kernel is used.
+	Hostname string
+
+	// Level is the minimum level of messages that are logged. If this is
+	// nil slog.LevelInfo is used.
+	Level slog.Leveler
+}
+
+// A Handler is a slog.Handler that writes records to syslog. Each record
+// is written as an RFC 5424 message with the record's severity mapped to
+// the syslog severity and its attributes appended to the message as
+// key=value pairs. If a write fails the handler reconnects to the syslog
+// daemon and tries once more before reporting the error.
+type Handler struct {
+	w      *writer
+	opts   Options
+	prefix string
+	attrs  []byte
+	pid    string
+}
+
+// NewHandler creates a Handler using the given options. If opts is nil
+// the default options are used. The connection to syslog is made when the
+// first message is written.
+func NewHandler(opts *Options) *Handler {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Facility == Kern {
+		o.Facility = Daemon
+	}
+	if o.AppName == "" {
+		o.AppName = filepath.Base(os.Args[0])
+	}
+	if o.Hostname == "" {
+		o.Hostname, _ = os.Hostname()
+	}
+	return &Handler{
+		w:    &writer{network: o.Network, address: o.Address},
+		opts: o,
+		pid:  strconv.Itoa(os.Getpid()),
+	}
+}
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return level >= min
+}
+
+// Handle implements slog.Handler.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s - - ",
+		int(h.opts.Facility)*8+severity(r.Level),
+		t.Format(time.RFC3339Nano),
+		header(h.opts.Hostname),
+		header(h.opts.AppName),
+		h.pid,
+	)
+	buf.WriteString(r.Message)
+	buf.Write(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&buf, h.prefix, a)
+		return true
+	})
+	return h.w.write(buf.Bytes())
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	var buf bytes.Buffer
+	buf.Write(h.attrs)
+	for _, a := range attrs {
+		appendAttr(&buf, h.prefix, a)
+	}
+	h2.attrs = buf.Bytes()
+	return &h2
+}
+
+// WithGroup implements slog.Handler.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// Close closes the connection to syslog.
+func (h *Handler) Close() error {
+	return h.w.close()
+}
+
+func severity(l slog.Level) int {
+	switch {
+	case l >= slog.LevelError:
+		return severityError
+	case l >= slog.LevelWarn:
+		return severityWarning
+	case l >= slog.LevelInfo:
+		return severityInfo
+	default:
+		return severityDebug
+	}
+}
+
+// header formats a header field, which must be printable ASCII with no
+// spaces; empty fields are the nil value "-".
+func header(s string) string {
+	b := []byte(s)
+	n := 0
+	for _, c := range b {
+		if c > ' ' && c < 0x7f {
+			b[n] = c
+			n++
+		}
+	}
+	if n == 0 {
+		return "-"
+	}
+	return string(b[:n])
+}
+
+func appendAttr(buf *bytes.Buffer, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			appendAttr(buf, prefix, ga)
+		}
+		return
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(prefix)
+	buf.WriteString(a.Key)
+	buf.WriteByte('=')
+	s := a.Value.String()
+	if needsQuoting(s) {
+		s = strconv.Quote(s)
+	}
+	buf.WriteString(s)
+}
+
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r == '"' || r == '=' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// A writer sends messages to syslog, reconnecting as necessary.
+type writer struct {
+	network string
+	address string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	stream bool
+}
+
+func (w *writer) write(msg []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			if err = w.dial(); err != nil {
+				continue
+			}
+		}
+		if err = w.send(msg); err == nil {
+			return nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+	return err
+}
+
+func (w *writer) send(msg []byte) error {
+	if w.stream {
+		// Stream transports use octet-counting framing (RFC 6587).
+		if _, err := fmt.Fprintf(w.conn, "%d ", len(msg)); err != nil {
+			return err
+		}
+	}
+	_, err := w.conn.Write(msg)
+	return err
+}
+
+func (w *writer) dial() error {
+	if w.network != "" {
+		conn, err := net.Dial(w.network, w.address)
+		if err != nil {
+			return err
+		}
+		w.conn, w.stream = conn, w.network != "udp" && w.network != "unixgram"
+		return nil
+	}
+	for _, addr := range localAddrs {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := net.Dial(network, addr); err == nil {
+				w.conn, w.stream = conn, network == "unix"
+				return nil
+			}
+		}
+	}
+	return errors.New("syslog: cannot connect to local syslog")
+}
+
+func (w *writer) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
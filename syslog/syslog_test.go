@@ -0,0 +1,94 @@
+// Copyright 2021 Canonical Ltd.
+
+package syslog
+
+import (
+	"bufio"
+	"log/slog"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	h := NewHandler(&Options{
+		Network:  "udp",
+		Address:  pc.LocalAddr().String(),
+		Facility: Local3,
+		AppName:  "test app",
+		Hostname: "host1",
+		Level:    slog.LevelDebug,
+	})
+	defer h.Close()
+	logger := slog.New(h).With("component", "db").WithGroup("req")
+	logger.Warn("disk nearly full", "path", "/var/spool", "free", "1 GB")
+	logger.Debug("debugging")
+
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	for _, want := range []string{
+		// facility 19 * 8 + severity 4
+		`^<156>1 \S+ host1 testapp \d+ - - disk nearly full component=db req.path=/var/spool req.free="1 GB"$`,
+		`^<159>1 \S+ host1 testapp \d+ - - debugging component=db$`,
+	} {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !regexp.MustCompile(want).Match(buf[:n]) {
+			t.Errorf("message %q does not match %q", buf[:n], want)
+		}
+	}
+}
+
+func TestHandlerTCPReconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	msgC := make(chan string)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(c).ReadString('\n')
+			c.Close()
+			msgC <- line
+		}
+	}()
+
+	h := NewHandler(&Options{Network: "tcp", Address: l.Addr().String()})
+	defer h.Close()
+	logger := slog.New(h)
+	for _, msg := range []string{"first", "second"} {
+		logger.Info(msg + "\n")
+		got := <-msgC
+		if !strings.HasSuffix(got, " - - "+msg+"\n") {
+			t.Errorf("unexpected message %q", got)
+		}
+		// Break the connection so that the next write has to
+		// reconnect.
+		h.w.conn.Close()
+	}
+}
+
+func TestHandlerEnabled(t *testing.T) {
+	h := NewHandler(nil)
+	if h.Enabled(nil, slog.LevelDebug) {
+		t.Error("debug enabled by default")
+	}
+	if !h.Enabled(nil, slog.LevelInfo) {
+		t.Error("info not enabled by default")
+	}
+}
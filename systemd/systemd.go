@@ -0,0 +1,84 @@
+// Copyright 2021 Canonical Ltd.
+
+// Package systemd integrates services with the systemd service manager's
+// notification protocol, as used by units with Type=notify.
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/canonical/go-service"
+)
+
+// Notify sends the given state, such as "READY=1", to the service
+// manager. If the process was not started by a service manager expecting
+// notifications Notify does nothing.
+func Notify(state string) error {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return nil
+	}
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// Ready tells the service manager that the service has finished starting
+// up. Services using Attach do not need to call Ready.
+func Ready() error {
+	return Notify("READY=1")
+}
+
+// WatchdogInterval returns the interval within which the service manager
+// expects watchdog pings, and false if the watchdog is not enabled for
+// this process.
+func WatchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
+
+// Attach reports the lifecycle of the given service to the service
+// manager. A goroutine of the service sends READY=1 once the service is
+// ready, as reported by its Ready method, pings the watchdog at half the
+// watchdog interval, if the watchdog is enabled, and sends STOPPING=1 as
+// soon as the service starts shutting down. Errors sending notifications
+// are ignored, as the service manager will act on any that are missed.
+func Attach(svc *service.Service) {
+	svc.Go(func() error {
+		var tick <-chan time.Time
+		if d, ok := WatchdogInterval(); ok {
+			t := time.NewTicker(d / 2)
+			defer t.Stop()
+			tick = t.C
+		}
+		ready := svc.Ready()
+		for {
+			select {
+			case <-ready:
+				Ready()
+				ready = nil
+			case <-tick:
+				Notify("WATCHDOG=1")
+			case <-svc.ShuttingDown():
+				Notify("STOPPING=1")
+				return nil
+			}
+		}
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 Canonical Ltd.
+
+package systemd
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-service"
+)
+
+func listen(t *testing.T) *net.UnixConn {
+	addr := &net.UnixAddr{Name: filepath.Join(t.TempDir(), "notify"), Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", addr.Name)
+	return conn
+}
+
+func read(t *testing.T, conn *net.UnixConn) string {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 256)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestNotifyNoSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Ready(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestAttach(t *testing.T) {
+	conn := listen(t)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", "")
+
+	_, svc := service.NewService(context.Background())
+	Attach(svc)
+	errC := make(chan error)
+	go func() { errC <- svc.Wait() }()
+	msgs := map[string]bool{}
+	for !msgs["READY=1"] || !msgs["WATCHDOG=1"] {
+		msgs[read(t, conn)] = true
+	}
+	svc.Shutdown(nil)
+	if err := <-errC; err != nil {
+		t.Error("unexpected error:", err)
+	}
+	for {
+		if msg := read(t, conn); msg != "WATCHDOG=1" {
+			if msg != "STOPPING=1" {
+				t.Errorf("unexpected message %q", msg)
+			}
+			break
+		}
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "3000000")
+	t.Setenv("WATCHDOG_PID", "1")
+	if _, ok := WatchdogInterval(); ok {
+		t.Error("watchdog enabled for another process")
+	}
+	t.Setenv("WATCHDOG_PID", "")
+	if d, ok := WatchdogInterval(); !ok || d != 3*time.Second {
+		t.Error("unexpected interval:", d, ok)
+	}
+}
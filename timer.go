@@ -0,0 +1,199 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A Timer is a single event timer, like a time.Timer, that is stopped
+// when the service starts shutting down. Its channel is closed at the
+// same time, so that a goroutine waiting for the timer returns without
+// also selecting on the service's context.
+type Timer struct {
+	// C receives the time when the timer fires. It is closed when the
+	// service starts shutting down, unless the timer has been stopped.
+	C <-chan time.Time
+
+	ctx        context.Context
+	c          chan time.Time
+	mu         sync.Mutex
+	t          *time.Timer
+	closed     bool
+	unregister func() bool
+}
+
+// NewTimer creates a timer that sends the current time on its channel
+// after at least the given duration.
+func (s *Service) NewTimer(d time.Duration) *Timer {
+	c := make(chan time.Time, 1)
+	t := &Timer{C: c, ctx: s.groupCtx, c: c}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.t = time.AfterFunc(d, t.fire)
+	t.unregister = context.AfterFunc(t.ctx, t.close)
+	return t
+}
+
+// Stop prevents the timer from firing, and stops its channel from being
+// closed when the service shuts down, so that the timer can be released.
+// It returns false if the timer had already fired, been stopped or been
+// closed. Reset rearms a stopped timer.
+func (t *Timer) Stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	if t.unregister != nil {
+		t.unregister()
+		t.unregister = nil
+	}
+	return t.t.Stop()
+}
+
+// Reset changes the timer to fire after the given duration. It returns
+// true if the timer had been active. Resetting a timer after the service
+// has started shutting down has no effect.
+func (t *Timer) Reset(d time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	if t.unregister == nil {
+		t.unregister = context.AfterFunc(t.ctx, t.close)
+	}
+	return t.t.Reset(d)
+}
+
+// fire sends the current time on the timer's channel, unless it is full.
+func (t *Timer) fire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.c <- time.Now():
+	default:
+	}
+}
+
+// close stops the timer and closes its channel.
+func (t *Timer) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+	t.t.Stop()
+	close(t.c)
+}
+
+// A Ticker delivers ticks at intervals, like a time.Ticker, until the
+// service starts shutting down, when its channel is closed.
+type Ticker struct {
+	// C receives the ticks. It is closed when the service starts
+	// shutting down, unless the ticker has been stopped.
+	C <-chan time.Time
+
+	ctx        context.Context
+	c          chan time.Time
+	mu         sync.Mutex
+	t          *time.Timer
+	d          time.Duration
+	next       time.Time
+	stopped    bool
+	closed     bool
+	unregister func() bool
+}
+
+// NewTicker creates a ticker that sends the current time on its channel
+// at the given interval. As with a time.Ticker, ticks are dropped for
+// slow receivers. The interval must be greater than zero.
+func (s *Service) NewTicker(d time.Duration) *Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	c := make(chan time.Time, 1)
+	t := &Ticker{C: c, ctx: s.groupCtx, c: c, d: d, next: time.Now().Add(d)}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.t = time.AfterFunc(d, t.tick)
+	t.unregister = context.AfterFunc(t.ctx, t.close)
+	return t
+}
+
+// Stop turns off the ticker, and stops its channel from being closed
+// when the service shuts down, so that the ticker can be released. Reset
+// restarts a stopped ticker.
+func (t *Ticker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.stopped = true
+	if t.unregister != nil {
+		t.unregister()
+		t.unregister = nil
+	}
+	t.t.Stop()
+}
+
+// Reset stops the ticker and resets its interval to the given duration,
+// which must be greater than zero. Resetting a ticker after the service
+// has started shutting down has no effect.
+func (t *Ticker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.stopped = false
+	t.d = d
+	t.next = time.Now().Add(d)
+	if t.unregister == nil {
+		t.unregister = context.AfterFunc(t.ctx, t.close)
+	}
+	t.t.Reset(d)
+}
+
+// tick sends the current time on the ticker's channel, unless it is
+// full, and schedules the next tick.
+func (t *Ticker) tick() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed || t.stopped {
+		return
+	}
+	now := time.Now()
+	select {
+	case t.c <- now:
+	default:
+	}
+	t.next = t.next.Add(t.d)
+	if !t.next.After(now) {
+		t.next = now.Add(t.d)
+	}
+	t.t.Reset(t.next.Sub(now))
+}
+
+// close stops the ticker and closes its channel.
+func (t *Ticker) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+	t.t.Stop()
+	close(t.c)
+}
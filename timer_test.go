@@ -0,0 +1,61 @@
+// Copyright 2021 Canonical Ltd.
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTimer(t *testing.T) {
+	_, svc := NewService(context.Background())
+	timer := svc.NewTimer(time.Millisecond)
+	if _, ok := <-timer.C; !ok {
+		t.Error("timer closed before firing")
+	}
+	if timer.Reset(time.Hour) {
+		t.Error("fired timer reported as active")
+	}
+	stopped := svc.NewTimer(time.Hour)
+	if !stopped.Stop() {
+		t.Error("active timer not stopped")
+	}
+
+	svc.Shutdown(nil)
+	if _, ok := <-timer.C; ok {
+		t.Error("timer not closed at shutdown")
+	}
+	if timer.Reset(time.Millisecond) {
+		t.Error("timer reset after shutdown")
+	}
+	select {
+	case <-stopped.C:
+		t.Error("stopped timer closed at shutdown")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestTicker(t *testing.T) {
+	_, svc := NewService(context.Background())
+	ticker := svc.NewTicker(time.Millisecond)
+	n := 0
+	svc.Go(func() error {
+		for range ticker.C {
+			n++
+			if n == 3 {
+				svc.Shutdown(nil)
+			}
+		}
+		return nil
+	})
+	if err := svc.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if n < 3 {
+		t.Error("unexpected number of ticks:", n)
+	}
+}